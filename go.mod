@@ -2,7 +2,10 @@ module goflow
 
 go 1.23.6
 
-require github.com/openai/openai-go v0.1.0-alpha.59
+require (
+	github.com/openai/openai-go v0.1.0-alpha.59
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/tidwall/gjson v1.14.4 // indirect
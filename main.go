@@ -1,42 +1,38 @@
 package main
 
 import (
+	"context"
+	"embed"
 	"fmt"
 	"log"
 
 	"goflow/pkg/components"
 	"goflow/pkg/flows"
 	"goflow/pkg/llms/openai"
+	"goflow/pkg/prompts"
 	"goflow/pkg/tools"
 )
 
+//go:embed prompts
+var promptFiles embed.FS
+
+// DomainAnalysis is the final output declared by the domain-analysis prompt.
+type DomainAnalysis struct {
+	Domain          string `json:"domain"`
+	Analysis        string `json:"analysis"`
+	SecurityPosture string `json:"security_posture"`
+	Recommendations string `json:"recommendations"`
+}
+
 func main() {
-	// 1. Define final output schema fields
-	finalSchema := []components.SchemaField{
-		{
-			Field:       "domain",
-			Description: "The domain being analyzed",
-			Type:        "string",
-			Required:    true,
-		},
-		{
-			Field:       "analysis",
-			Description: "Complete analysis of the domain",
-			Type:        "string",
-			Required:    true,
-		},
-		{
-			Field:       "security_posture",
-			Description: "Overall security assessment",
-			Type:        "string",
-			Required:    true,
-		},
-		{
-			Field:       "recommendations",
-			Description: "Recommended actions",
-			Type:        "string",
-			Required:    true,
-		},
+	// 1. Load the pinned prompt, which also defines the final output schema
+	registry := prompts.NewRegistry()
+	if err := registry.LoadFS(promptFiles, "prompts"); err != nil {
+		log.Fatalf("Failed to load prompts: %v", err)
+	}
+	analysisPrompt, err := registry.Get("domain-analysis", "1.0.0")
+	if err != nil {
+		log.Fatalf("Failed to get prompt: %v", err)
 	}
 
 	// 2. Set up tools
@@ -46,11 +42,18 @@ func main() {
 	}
 	customToolList := tools.CreateTools(customTools)
 
-	// 3. Client setup
+	// 3. Build the prompt; it checks the variables and picks the tools it names
+	prompt, err := analysisPrompt.Prompt(map[string]interface{}{
+		"domain": "recordedfuture.com",
+	}, customToolList)
+	if err != nil {
+		log.Fatalf("Failed to build prompt: %v", err)
+	}
+
+	// 4. Client setup, with the sampling settings the prompt was tuned for
 	clientConfig := components.ClientConfig{
-		Model:       "gpt-4",
-		Temperature: 0.1,
-		MaxTokens:   1000,
+		Model:  analysisPrompt.Model.Name,
+		Params: prompt.Params,
 	}
 
 	client, err := openai.NewOpenAIClient(clientConfig)
@@ -58,41 +61,15 @@ func main() {
 		log.Fatalf("Failed to create OpenAI client: %v", err)
 	}
 
-	// 4. Create the Prompts
-	systemMessage := `You are an AI analyst specialized in domain analysis. Your role is to:
-                1. First, examine the tools available to you. These will be your only source of data.
-                2. Plan your analysis based ONLY on the tools you have access to.
-                3. For each tool:
-                - State what information you plan to gather
-                - Use the tool to collect the data
-                - Analyze the results
-                4. Once you have exhausted all available tools:
-                - Summarize all gathered data
-                - Provide analysis based ONLY on the information collected from these tools
-                - Do not make assumptions about data you cannot verify with your tools
-                - Clearly state if there are important security aspects you cannot assess due to tool limitations
-
-                Important: 
-                - Do not attempt to access external resources or tools not explicitly provided
-                - If you need information but don't have the appropriate tool, note this in your analysis
-                - Structure your findings based solely on verifiable data from your available tools
-
-                Return your analysis in the specified JSON format once you have completed your investigation or can no longer gather more data with your current tools.`
-
-	userMessage := `Please analyze the security posture of this domain: {{domain}}.Start by gathering basic information and then dig deeper based on what you find.`
-
-	inputContext := map[string]interface{}{
-		"domain": "recordedfuture.com",
-	}
-
 	// 5. Run the CoT Workflow
 	result, err := flows.CoTWorkFlow(
+		context.Background(),
 		client,
-		systemMessage,
-		userMessage,
-		finalSchema,
-		inputContext,
-		customToolList,
+		prompt.SystemMessage,
+		prompt.UserMessage,
+		prompt.OutputFormat.Fields,
+		prompt.Variables,
+		prompt.Tools,
 	)
 	if err != nil {
 		log.Fatalf("Error Running Flow: %v", err)
@@ -120,11 +97,13 @@ func main() {
 	}
 
 	// Print final analysis
-	if finalOutput, ok := data["final_output"].(map[string]interface{}); ok {
-		fmt.Println("\nFinal Analysis:")
-		fmt.Printf("Domain: %s\n", finalOutput["domain"])
-		fmt.Printf("Analysis: %s\n", finalOutput["analysis"])
-		fmt.Printf("Security Posture: %s\n", finalOutput["security_posture"])
-		fmt.Printf("Recommendations: %s\n", finalOutput["recommendations"])
+	finalOutput, err := components.Decode[DomainAnalysis](data["final_output"])
+	if err != nil {
+		log.Fatalf("Invalid final output: %v", err)
 	}
+	fmt.Println("\nFinal Analysis:")
+	fmt.Printf("Domain: %s\n", finalOutput.Domain)
+	fmt.Printf("Analysis: %s\n", finalOutput.Analysis)
+	fmt.Printf("Security Posture: %s\n", finalOutput.SecurityPosture)
+	fmt.Printf("Recommendations: %s\n", finalOutput.Recommendations)
 }
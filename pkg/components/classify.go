@@ -0,0 +1,382 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Ways a Classification's confidence was measured.
+const (
+	ConfidenceLogprobs = "logprobs"
+	ConfidenceSampling = "sampling"
+)
+
+// defaultClassificationSamples is how many answers WorkFlowClassify
+// samples when the client cannot report log probabilities.
+const defaultClassificationSamples = 5
+
+// classificationAlternatives is how many alternative tokens are requested
+// per position when reading confidence from log probabilities.
+const classificationAlternatives = 10
+
+// Classification is the result of a WorkFlowClassify run.
+type Classification struct {
+	// Label is always one of the declared labels, spelled as declared.
+	Label string `json:"label"`
+	// Confidence is between 0 and 1: the probability the model gave the
+	// label with logprobs (see logprobClassification), or the share of
+	// samples that chose Label.
+	Confidence float64 `json:"confidence"`
+	// Scores holds the same measure for every label that received any.
+	Scores map[string]float64 `json:"scores,omitempty"`
+	// Method is ConfidenceLogprobs or ConfidenceSampling.
+	Method string `json:"method"`
+}
+
+// ClassificationParser maps a free-text answer onto one of a closed set of
+// labels. Case, spacing, '-' and '_' are ignored, a label mentioned in a
+// sentence is found when it is the only one, and near misses within a few
+// edits are accepted. Anything else is rejected. Parse returns a map with
+// the canonical label under "label".
+type ClassificationParser struct {
+	Labels []string
+
+	patterns []*regexp.Regexp // word-bounded normalized Labels, in order
+}
+
+// NewClassificationParser creates a parser with its label patterns
+// compiled.
+func NewClassificationParser(labels []string) *ClassificationParser {
+	return &ClassificationParser{Labels: labels, patterns: labelPatterns(labels)}
+}
+
+func labelPatterns(labels []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(labels))
+	for i, label := range labels {
+		patterns[i] = regexp.MustCompile(`\b` + regexp.QuoteMeta(normalizeLabel(label)) + `\b`)
+	}
+	return patterns
+}
+
+func (p *ClassificationParser) Parse(input string) (interface{}, error) {
+	label, err := p.Match(input)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"label": label}, nil
+}
+
+// ValidateSchema checks that a decoded result holds a declared label.
+func (p *ClassificationParser) ValidateSchema(value interface{}) error {
+	object, _ := value.(map[string]interface{})
+	label, _ := object["label"].(string)
+	for _, allowed := range p.Labels {
+		if label == allowed {
+			return nil
+		}
+	}
+	return p.unknown(label)
+}
+
+// Match returns the canonical label for an answer.
+func (p *ClassificationParser) Match(answer string) (string, error) {
+	if len(p.Labels) == 0 {
+		return "", fmt.Errorf("classification has no labels")
+	}
+	text := cleanAnswer(answer)
+	if text == "" {
+		return "", p.unknown(answer)
+	}
+
+	normalized := normalizeLabel(text)
+	for _, label := range p.Labels {
+		if normalizeLabel(label) == normalized {
+			return label, nil
+		}
+	}
+
+	if label, ok := p.mentioned(normalized); ok {
+		return label, nil
+	}
+
+	best, bestDistance, tied := "", math.MaxInt, false
+	for _, label := range p.Labels {
+		target := normalizeLabel(label)
+		d := editDistance(normalized, target)
+		if d > max(1, len([]rune(target))/4) {
+			continue
+		}
+		switch {
+		case d < bestDistance:
+			best, bestDistance, tied = label, d, false
+		case d == bestDistance:
+			tied = true
+		}
+	}
+	if best != "" && !tied {
+		return best, nil
+	}
+	return "", p.unknown(answer)
+}
+
+// mentioned finds the label named in a longer answer such as "The category
+// is phishing." When several labels appear, one containing all the others
+// ("not spam" over "spam") wins; otherwise the answer is ambiguous.
+func (p *ClassificationParser) mentioned(normalized string) (string, bool) {
+	patterns := p.patterns
+	if len(patterns) != len(p.Labels) {
+		patterns = labelPatterns(p.Labels)
+	}
+	var found []string
+	for i, re := range patterns {
+		if re.MatchString(normalized) {
+			found = append(found, p.Labels[i])
+		}
+	}
+	if len(found) == 0 {
+		return "", false
+	}
+	longest := found[0]
+	for _, label := range found[1:] {
+		if len(normalizeLabel(label)) > len(normalizeLabel(longest)) {
+			longest = label
+		}
+	}
+	for _, label := range found {
+		if !strings.Contains(normalizeLabel(longest), normalizeLabel(label)) {
+			return "", false
+		}
+	}
+	return longest, true
+}
+
+func (p *ClassificationParser) unknown(answer string) error {
+	return &ValidationError{Violations: []Violation{{
+		Path:    "/label",
+		Keyword: "enum",
+		Message: fmt.Sprintf("%q is not one of %s", truncateForMessage(strings.TrimSpace(answer)), strings.Join(p.Labels, ", ")),
+	}}}
+}
+
+var (
+	answerPrefix    = regexp.MustCompile(`(?i)^(?:label|category|class|classification|answer)\s*[:=-]\s*`)
+	labelSeparators = regexp.MustCompile(`[\s_-]+`)
+)
+
+// cleanAnswer strips the wrapping models put around a bare label: code
+// fences, quotes, markdown emphasis, a "Label:" prefix, trailing
+// punctuation or a JSON object with a "label" field.
+func cleanAnswer(answer string) string {
+	text := strings.TrimSpace(answer)
+	text = strings.TrimSpace(strings.Trim(text, "`"))
+	text = strings.TrimPrefix(text, "json\n")
+
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &object); err == nil {
+		for _, key := range []string{"label", "category", "class"} {
+			if s, ok := object[key].(string); ok {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+
+	text = answerPrefix.ReplaceAllString(text, "")
+	text = strings.Trim(text, " \t\r\n\"'*.!")
+	return text
+}
+
+// normalizeLabel lowercases s and treats runs of spaces, '-' and '_' as a
+// single space.
+func normalizeLabel(s string) string {
+	return strings.TrimSpace(labelSeparators.ReplaceAllString(strings.ToLower(s), " "))
+}
+
+// editDistance is the Levenshtein distance between a and b in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// logprobClassification scores label from the log probabilities of the
+// answer that produced it, at the first token that starts a label, so
+// wording before the label does not count. Scores spread that token's
+// alternatives over the labels they can only be the start of, and
+// Confidence is the label's own score. When the label shares its first
+// token with another label, Confidence is instead the probability of the
+// label's own tokens. It reports false when no token starts a label.
+func logprobClassification(parser *ClassificationParser, label string, tokens []TokenLogprob) (Classification, bool) {
+	start := -1
+	for i, token := range tokens {
+		if text := tokenText(token.Token); text != "" && parser.startsLabel(text) != "" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return Classification{}, false
+	}
+	result := Classification{Label: label, Method: ConfidenceLogprobs}
+
+	alternatives := tokens[start].TopLogprobs
+	if len(alternatives) == 0 {
+		alternatives = []TopLogprob{{Token: tokens[start].Token, Logprob: tokens[start].Logprob}}
+	}
+	scores := make(map[string]float64)
+	for _, alt := range alternatives {
+		if text := tokenText(alt.Token); text != "" {
+			if match := parser.startsLabel(text); match != "" && match != ambiguousLabel {
+				scores[match] += math.Exp(alt.Logprob)
+			}
+		}
+	}
+	if len(scores) > 0 {
+		result.Scores = scores
+	}
+	if score, ok := scores[label]; ok {
+		result.Confidence = score
+		return result, true
+	}
+
+	want := len(normalizeLabel(label))
+	total, text := 0.0, ""
+	for _, token := range tokens[start:] {
+		total += token.Logprob
+		text += token.Token
+		if len(tokenText(text)) >= want {
+			break
+		}
+	}
+	result.Confidence = math.Exp(total)
+	return result, true
+}
+
+// ambiguousLabel is returned by startsLabel for text that starts more than
+// one label.
+const ambiguousLabel = "\x00"
+
+// startsLabel returns the label whose normalized form starts with text,
+// ambiguousLabel when several do, or "" when none does.
+func (p *ClassificationParser) startsLabel(text string) string {
+	var match string
+	for _, candidate := range p.Labels {
+		if strings.HasPrefix(normalizeLabel(candidate), text) {
+			if match != "" {
+				return ambiguousLabel
+			}
+			match = candidate
+		}
+	}
+	return match
+}
+
+// tokenText normalizes generated text for comparison with labels, dropping
+// the quotes, emphasis and punctuation around it.
+func tokenText(token string) string {
+	return normalizeLabel(strings.Trim(strings.TrimSpace(token), "\"'`*.,:;!"))
+}
+
+// classify answers the prompt with one of the parser's labels. With a
+// client that reports logprobs a single answer is scored; otherwise
+// Config.Samples answers (five by default) are drawn and the most common
+// label wins. Sampling uses the configured temperature, which should be
+// above zero for the votes to mean anything.
+func (wf *WorkFlow) classify(ctx context.Context, prompt Prompt, run *RunRecord) (*Classification, error) {
+	parser, ok := wf.OutputParser.(*ClassificationParser)
+	if !ok {
+		parser = NewClassificationParser(prompt.OutputFormat.Labels)
+	}
+	if len(parser.Labels) == 0 {
+		return nil, fmt.Errorf("classification needs OutputFormat.Labels or a ClassificationParser")
+	}
+
+	samples := wf.Config.Samples
+	if samples <= 0 {
+		samples = defaultClassificationSamples
+	}
+	var choices []Choice
+	// Answers already recorded as attempts
+	recorded := 0
+
+	if wf.Client.GetModelInfo().Capabilities["logprobs"] {
+		scored := prompt
+		scored.Params.TopLogprobs = Int(classificationAlternatives)
+		scored.Params.N = nil
+		completion, err := wf.completion(ctx, scored, run, nil)
+		if err != nil {
+			return nil, err
+		}
+		choice := completion.Choices[0]
+		label, err := parser.Match(choice.Content)
+		run.addAttempt(choice.Content, err, nil)
+		if err == nil {
+			if result, ok := logprobClassification(parser, label, choice.Logprobs); ok {
+				return &result, nil
+			}
+		}
+		// No usable logprobs; count this answer as the first sample
+		choices = append(choices, choice)
+		recorded = 1
+	}
+
+	for len(choices) < samples {
+		next := prompt
+		next.Params.N = Int(int64(samples - len(choices)))
+		completion, err := wf.completion(ctx, next, run, nil)
+		if err != nil {
+			return nil, err
+		}
+		// Providers that ignore N return one choice per request
+		choices = append(choices, completion.Choices[:min(len(completion.Choices), samples-len(choices))]...)
+	}
+
+	votes := make(map[string]int)
+	var order []string
+	var lastErr error
+	for i, choice := range choices {
+		label, err := parser.Match(choice.Content)
+		if i >= recorded {
+			run.addAttempt(choice.Content, err, nil)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if votes[label] == 0 {
+			order = append(order, label)
+		}
+		votes[label]++
+	}
+	if len(order) == 0 {
+		return nil, lastErr
+	}
+
+	result := &Classification{Method: ConfidenceSampling, Scores: make(map[string]float64, len(order))}
+	for _, label := range order {
+		result.Scores[label] = float64(votes[label]) / float64(len(choices))
+		if votes[label] > votes[result.Label] {
+			result.Label = label
+		}
+	}
+	result.Confidence = result.Scores[result.Label]
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package components
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClassificationParserMatch(t *testing.T) {
+	labels := []string{"spam", "not spam", "phishing"}
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"spam", "spam"},
+		{"  **Phishing.** ", "phishing"},
+		{"Label: not_spam", "not spam"},
+		{`{"label": "spam"}`, "spam"},
+		{"The message is not spam.", "not spam"},
+		{"phising", "phishing"},
+		{"malware", ""},
+	}
+	// A parser built as a struct literal compiles its patterns as it goes
+	for _, parser := range []*ClassificationParser{NewClassificationParser(labels), {Labels: labels}} {
+		for _, tt := range tests {
+			got, err := parser.Match(tt.answer)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("Match(%q) = %q, want an error", tt.answer, got)
+				}
+				continue
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Match(%q) = %q, %v; want %q", tt.answer, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestLogprobClassification(t *testing.T) {
+	parser := NewClassificationParser([]string{"phishing", "benign", "not sure"})
+	lp := math.Log
+
+	// The label follows a sentence; only the label's first token counts
+	tokens := []TokenLogprob{
+		{Token: "The", Logprob: lp(0.5)},
+		{Token: " category", Logprob: lp(0.5)},
+		{Token: " is", Logprob: lp(0.5)},
+		{Token: " phish", Logprob: lp(0.8), TopLogprobs: []TopLogprob{
+			{Token: " phish", Logprob: lp(0.8)},
+			{Token: " benign", Logprob: lp(0.15)},
+			{Token: " the", Logprob: lp(0.05)},
+		}},
+		{Token: "ing", Logprob: lp(0.99)},
+	}
+	got, ok := logprobClassification(parser, "phishing", tokens)
+	if !ok {
+		t.Fatal("no classification")
+	}
+	if !near(got.Confidence, 0.8) || !near(got.Scores["phishing"], 0.8) || !near(got.Scores["benign"], 0.15) {
+		t.Errorf("got %+v, want confidence 0.8 matching Scores", got)
+	}
+
+	// A label sharing its first token with another label is scored by its
+	// own tokens
+	parser = NewClassificationParser([]string{"not spam", "not sure"})
+	tokens = []TokenLogprob{
+		{Token: "not", Logprob: lp(0.9)},
+		{Token: " sure", Logprob: lp(0.5)},
+		{Token: ".", Logprob: lp(0.9)},
+	}
+	got, ok = logprobClassification(parser, "not sure", tokens)
+	if !ok || !near(got.Confidence, 0.45) {
+		t.Errorf("got %+v, %v; want confidence 0.45 from the label's tokens", got, ok)
+	}
+
+	if _, ok := logprobClassification(parser, "not sure", []TokenLogprob{{Token: "Hmm", Logprob: lp(0.9)}}); ok {
+		t.Error("classified an answer with no label token")
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,69 @@
+package components
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Provider-agnostic failure categories. Every LLMClient maps its errors onto
+// one of these so callers can branch with errors.Is regardless of vendor.
+var (
+	ErrRateLimited           = errors.New("rate limited")
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+	ErrContentFiltered       = errors.New("content filtered")
+	ErrAuth                  = errors.New("authentication failed")
+	ErrTimeout               = errors.New("request timed out")
+	ErrProviderUnavailable   = errors.New("provider unavailable")
+	ErrInvalidRequest        = errors.New("invalid request")
+	ErrEmptyResponse         = errors.New("empty response")
+)
+
+// LLMError is the error returned by LLMClient implementations. Kind is one of
+// the sentinel errors above and Err is the underlying provider error, so both
+// errors.Is(err, ErrRateLimited) and errors.As(err, &llmErr) work.
+type LLMError struct {
+	Kind       error
+	Provider   string
+	StatusCode int
+	// RetryAfter is the provider's requested back-off, if it sent one.
+	RetryAfter time.Duration
+	Message    string
+	Err        error
+}
+
+func (e *LLMError) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Provider, e.Kind)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *LLMError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// RetryAfter reports the back-off requested by a rate-limited provider.
+func RetryAfter(err error) (time.Duration, bool) {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) && llmErr.RetryAfter > 0 {
+		return llmErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrProviderUnavailable)
+}
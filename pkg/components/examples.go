@@ -0,0 +1,211 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Example is a few-shot input/output pair.
+type Example struct {
+	Input  string
+	Output string
+}
+
+// How Prompt.Examples are presented to the model.
+const (
+	// ExamplesAsTurns sends each example as a user turn followed by an
+	// assistant turn, between the system and user messages.
+	ExamplesAsTurns = "turns"
+	// ExamplesInline appends the examples to the system message as a block.
+	ExamplesInline = "inline"
+)
+
+// ExampleSelector picks the examples to include for a query, which is the
+// rendered user message.
+type ExampleSelector interface {
+	Select(ctx context.Context, query string, examples []Example) ([]Example, error)
+}
+
+// Embedder turns texts into vectors for similarity search.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// EstimateTokens gives a rough token count for budgeting, at about four
+// characters per token.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// exampleTurns renders examples as alternating user and assistant turns.
+func exampleTurns(examples []Example) []Message {
+	turns := make([]Message, 0, 2*len(examples))
+	for _, ex := range examples {
+		turns = append(turns,
+			Message{Role: RoleUser, Content: ex.Input},
+			Message{Role: RoleAssistant, Content: ex.Output},
+		)
+	}
+	return turns
+}
+
+// exampleBlock renders examples as a block for the system message.
+func exampleBlock(examples []Example) string {
+	if len(examples) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nExamples:")
+	for i, ex := range examples {
+		fmt.Fprintf(&b, "\n\nExample %d\nInput:\n%s\nOutput:\n%s", i+1, ex.Input, ex.Output)
+	}
+	return b.String()
+}
+
+// scoredExample pairs an example with its relevance to the query.
+type scoredExample struct {
+	example Example
+	score   float64
+}
+
+// pickExamples takes the highest scoring examples, at most k of them (zero
+// means no limit) and within budget estimated tokens (zero means no limit).
+// The result is ordered least to most relevant so the best match sits next
+// to the user message.
+func pickExamples(scored []scoredExample, k int, budget int) []Example {
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+
+	var picked []scoredExample
+	used := 0
+	for _, s := range scored {
+		if k > 0 && len(picked) == k {
+			break
+		}
+		cost := EstimateTokens(s.example.Input) + EstimateTokens(s.example.Output)
+		if budget > 0 && used+cost > budget {
+			continue
+		}
+		used += cost
+		picked = append(picked, s)
+	}
+
+	result := make([]Example, len(picked))
+	for i, s := range picked {
+		result[len(picked)-1-i] = s.example
+	}
+	return result
+}
+
+// KeywordSelector ranks examples by how many distinct keywords their input
+// shares with the query.
+type KeywordSelector struct {
+	K           int // maximum examples, zero for no limit
+	TokenBudget int // maximum estimated tokens, zero for no limit
+}
+
+func (s KeywordSelector) Select(ctx context.Context, query string, examples []Example) ([]Example, error) {
+	queryWords := keywords(query)
+	scored := make([]scoredExample, len(examples))
+	for i, ex := range examples {
+		overlap := 0
+		for word := range keywords(ex.Input) {
+			if queryWords[word] {
+				overlap++
+			}
+		}
+		scored[i] = scoredExample{example: ex, score: float64(overlap)}
+	}
+	return pickExamples(scored, s.K, s.TokenBudget), nil
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "with": true,
+}
+
+func keywords(text string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		if len(word) > 1 && !stopWords[word] {
+			set[word] = true
+		}
+	}
+	return set
+}
+
+// EmbeddingSelector ranks examples by cosine similarity between the query
+// and each example input. Example embeddings are cached across calls.
+type EmbeddingSelector struct {
+	Embedder    Embedder
+	K           int // maximum examples, zero for no limit
+	TokenBudget int // maximum estimated tokens, zero for no limit
+
+	mu    sync.Mutex
+	cache map[string][]float64
+}
+
+func (s *EmbeddingSelector) Select(ctx context.Context, query string, examples []Example) ([]Example, error) {
+	if s.Embedder == nil {
+		return nil, fmt.Errorf("embedding selector has no embedder")
+	}
+
+	s.mu.Lock()
+	if s.cache == nil {
+		s.cache = make(map[string][]float64)
+	}
+	texts := []string{query}
+	for _, ex := range examples {
+		if _, ok := s.cache[ex.Input]; !ok {
+			texts = append(texts, ex.Input)
+		}
+	}
+	s.mu.Unlock()
+
+	vectors, err := s.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embedding examples: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+
+	s.mu.Lock()
+	for i, text := range texts[1:] {
+		s.cache[text] = vectors[i+1]
+	}
+	scored := make([]scoredExample, len(examples))
+	for i, ex := range examples {
+		scored[i] = scoredExample{example: ex, score: cosine(vectors[0], s.cache[ex.Input])}
+	}
+	s.mu.Unlock()
+
+	return pickExamples(scored, s.K, s.TokenBudget), nil
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
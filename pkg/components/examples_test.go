@@ -0,0 +1,139 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeEmbedder returns fixed vectors and records every batch it is asked
+// to embed.
+type fakeEmbedder struct {
+	vectors map[string][]float64
+	calls   [][]string
+	err     error
+}
+
+func (e *fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	e.calls = append(e.calls, append([]string(nil), texts...))
+	if e.err != nil {
+		return nil, e.err
+	}
+	result := make([][]float64, len(texts))
+	for i, text := range texts {
+		result[i] = e.vectors[text]
+	}
+	return result, nil
+}
+
+func inputs(examples []Example) []string {
+	result := make([]string, len(examples))
+	for i, ex := range examples {
+		result[i] = ex.Input
+	}
+	return result
+}
+
+func TestPickExamples(t *testing.T) {
+	scored := func() []scoredExample {
+		return []scoredExample{
+			{Example{Input: "low", Output: "x"}, 0.1},
+			{Example{Input: "best", Output: strings.Repeat("x", 40)}, 0.9},
+			{Example{Input: "tie-a", Output: "x"}, 0.5},
+			{Example{Input: "tie-b", Output: "x"}, 0.5},
+		}
+	}
+	tests := []struct {
+		name      string
+		k, budget int
+		want      []string
+	}{
+		{name: "no limits", want: []string{"low", "tie-b", "tie-a", "best"}},
+		{name: "k", k: 2, want: []string{"tie-a", "best"}},
+		// best costs 1+10 estimated tokens, low 1+1 and the ties 2+1
+		{name: "budget skips what does not fit", budget: 6, want: []string{"tie-b", "tie-a"}},
+		{name: "budget and k", k: 3, budget: 14, want: []string{"tie-a", "best"}},
+		{name: "budget too small", budget: 1, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inputs(pickExamples(scored(), tt.k, tt.budget)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pickExamples() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeywordSelector(t *testing.T) {
+	examples := []Example{
+		{Input: "Is the domain example.com safe?", Output: "a"},
+		{Input: "Summarize this article", Output: "b"},
+		{Input: "WHOIS lookup for a DOMAIN, then check its safety", Output: "c"},
+	}
+	got, err := KeywordSelector{K: 2}.Select(context.Background(), "Check the domain's WHOIS record", examples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Overlaps: domain and whois, check (3); domain (1); nothing (0)
+	if want := []string{examples[0].Input, examples[2].Input}; !reflect.DeepEqual(inputs(got), want) {
+		t.Errorf("Select() = %v, want %v", inputs(got), want)
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	got := keywords("The WHOIS of a-domain.com is: 42")
+	want := map[string]bool{"whois": true, "domain": true, "com": true, "42": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keywords() = %v, want %v", got, want)
+	}
+}
+
+func TestEmbeddingSelector(t *testing.T) {
+	embedder := &fakeEmbedder{vectors: map[string][]float64{
+		"query":  {1, 0},
+		"other":  {0.6, 0.8},
+		"near":   {0.9, 0.1},
+		"far":    {0, 1},
+		"second": {0.1, 0.9},
+	}}
+	examples := []Example{{Input: "far"}, {Input: "near"}, {Input: "other"}}
+	selector := &EmbeddingSelector{Embedder: embedder, K: 2}
+
+	got, err := selector.Select(context.Background(), "query", examples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"other", "near"}; !reflect.DeepEqual(inputs(got), want) {
+		t.Errorf("Select() = %v, want %v", inputs(got), want)
+	}
+
+	// Cached inputs are not embedded again; only the query and new ones are
+	got, err = selector.Select(context.Background(), "second", append(examples, Example{Input: "query"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"other", "far"}; !reflect.DeepEqual(inputs(got), want) {
+		t.Errorf("second Select() = %v, want %v", inputs(got), want)
+	}
+	wantCalls := [][]string{{"query", "far", "near", "other"}, {"second", "query"}}
+	if !reflect.DeepEqual(embedder.calls, wantCalls) {
+		t.Errorf("Embed calls = %v, want %v", embedder.calls, wantCalls)
+	}
+}
+
+func TestEmbeddingSelectorErrors(t *testing.T) {
+	if _, err := (&EmbeddingSelector{}).Select(context.Background(), "q", nil); err == nil {
+		t.Error("Select() without an embedder succeeded")
+	}
+
+	failure := errors.New("rate limited")
+	selector := &EmbeddingSelector{Embedder: &fakeEmbedder{err: failure}}
+	if _, err := selector.Select(context.Background(), "q", []Example{{Input: "a"}}); !errors.Is(err, failure) {
+		t.Errorf("Select() error = %v, want the embedder's error", err)
+	}
+	if len(selector.cache) != 0 {
+		t.Errorf("cache = %v after a failed embedding, want empty", selector.cache)
+	}
+}
@@ -0,0 +1,453 @@
+package components
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	mrand "math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// PromptVariant is one arm of an Experiment.
+type PromptVariant struct {
+	Name string
+	// Weight is the variant's relative share of traffic. A zero weight
+	// pauses the variant.
+	Weight float64
+	Prompt Prompt
+}
+
+// Experiment splits runs between prompt variants and records how each one
+// does. Set it on WorkFlow.Experiment; the workflow's own Prompt is then
+// ignored.
+type Experiment struct {
+	Name     string
+	Variants []PromptVariant
+	// Recorder stores a RunRecord per run. Runs are not recorded when nil.
+	Recorder *ExperimentRecorder
+}
+
+// Assign picks the variant for key. The same key always gets the same
+// variant as long as the names and weights do not change; an empty key
+// picks at random.
+func (e *Experiment) Assign(key string) (PromptVariant, error) {
+	total := 0.0
+	for _, v := range e.Variants {
+		if v.Weight < 0 {
+			return PromptVariant{}, fmt.Errorf("experiment %s: variant %s has negative weight", e.Name, v.Name)
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return PromptVariant{}, fmt.Errorf("experiment %s has no variants with traffic", e.Name)
+	}
+
+	var point float64
+	if key == "" {
+		point = mrand.Float64()
+	} else {
+		sum := sha256.Sum256([]byte(e.Name + "\x00" + key))
+		point = float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+	}
+	point *= total
+
+	for _, v := range e.Variants {
+		if point < v.Weight {
+			return v, nil
+		}
+		point -= v.Weight
+	}
+	// Rounding can leave point just past the last weight
+	for i := len(e.Variants) - 1; i >= 0; i-- {
+		if e.Variants[i].Weight > 0 {
+			return e.Variants[i], nil
+		}
+	}
+	return PromptVariant{}, fmt.Errorf("experiment %s has no variants with traffic", e.Name)
+}
+
+// RunRecord is the outcome of one run.
+type RunRecord struct {
+	ID         string `json:"id"`
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+	Key        string `json:"key,omitempty"`
+	Workflow   string `json:"workflow"`
+	// Model is the first model used; Models breaks Usage down by model
+	// for runs that use more than one.
+	Model   string        `json:"model,omitempty"`
+	Models  []ModelUsage  `json:"models,omitempty"`
+	Started time.Time     `json:"started"`
+	Latency time.Duration `json:"latency"`
+	Usage   Usage         `json:"usage"`
+	Cost    float64       `json:"cost"`
+	ParseOK bool          `json:"parse_ok"`
+	Error   string        `json:"error,omitempty"`
+	// Attempts holds every response the parser saw, including those
+	// rejected and sent back for correction.
+	Attempts []Attempt `json:"attempts,omitempty"`
+	// Feedback is a caller-supplied score, such as 1 for thumbs up and 0
+	// for thumbs down.
+	Feedback *float64 `json:"feedback,omitempty"`
+	Comment  string   `json:"comment,omitempty"`
+}
+
+// ModelUsage is the token usage of one model in a run.
+type ModelUsage struct {
+	Model string `json:"model"`
+	Usage Usage  `json:"usage"`
+}
+
+// Attempt is one response and what the parser made of it.
+type Attempt struct {
+	Response   string      `json:"response"`
+	Error      string      `json:"error,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+	// Guardrails lists what the workflow's guardrails rejected, repaired
+	// or annotated.
+	Guardrails []GuardrailViolation `json:"guardrails,omitempty"`
+}
+
+func (r *RunRecord) addAttempt(response string, err error, notes []GuardrailViolation) {
+	attempt := Attempt{Response: response, Guardrails: notes}
+	if err != nil {
+		attempt.Error = err.Error()
+		var verr *ValidationError
+		var gerr *GuardrailError
+		if errors.As(err, &verr) {
+			attempt.Violations = verr.Violations
+		} else if errors.As(err, &gerr) {
+			attempt.Guardrails = append(attempt.Guardrails, gerr.Violations...)
+		}
+	}
+	r.Attempts = append(r.Attempts, attempt)
+}
+
+// NewRunRecord starts a record with a fresh ID.
+func NewRunRecord(workflow string) *RunRecord {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		// Fall back to the clock; IDs only need to be unique per log
+		return &RunRecord{ID: fmt.Sprintf("%x", time.Now().UnixNano()), Workflow: workflow, Started: time.Now()}
+	}
+	return &RunRecord{ID: hex.EncodeToString(buf), Workflow: workflow, Started: time.Now()}
+}
+
+// AddUsage accumulates token usage from one completion, in total and for
+// its model.
+func (r *RunRecord) AddUsage(model string, usage Usage) {
+	if r.Model == "" {
+		r.Model = model
+	}
+	addUsage(&r.Usage, usage)
+	for i := range r.Models {
+		if r.Models[i].Model == model {
+			addUsage(&r.Models[i].Usage, usage)
+			return
+		}
+	}
+	r.Models = append(r.Models, ModelUsage{Model: model, Usage: usage})
+}
+
+// AddRunUsage accumulates the usage of another run, such as one step of a
+// multi-step flow, model by model.
+func (r *RunRecord) AddRunUsage(other *RunRecord) {
+	for _, m := range other.modelUsage() {
+		r.AddUsage(m.Model, m.Usage)
+	}
+}
+
+// modelUsage returns Models, or for a record without them, such as one
+// logged before they were kept, all of Usage under Model.
+func (r *RunRecord) modelUsage() []ModelUsage {
+	if len(r.Models) == 0 && (r.Model != "" || r.Usage != Usage{}) {
+		return []ModelUsage{{Model: r.Model, Usage: r.Usage}}
+	}
+	return r.Models
+}
+
+func addUsage(total *Usage, usage Usage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	total.ReasoningTokens += usage.ReasoningTokens
+}
+
+// AddAttempts appends the parse attempts of one step of a multi-step
+// flow, so the flow's record counts every step's attempts.
+func (r *RunRecord) AddAttempts(attempts []Attempt) {
+	r.Attempts = append(r.Attempts, attempts...)
+}
+
+// Pricing is a model's price in dollars per million tokens. Reasoning
+// tokens are billed as completion tokens.
+type Pricing struct {
+	PromptPerMillion     float64
+	CompletionPerMillion float64
+}
+
+// Cost prices usage.
+func (p Pricing) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*p.PromptPerMillion + float64(usage.CompletionTokens)*p.CompletionPerMillion) / 1e6
+}
+
+// ExperimentRecorder keeps run records in memory and, when opened on a
+// file, appends them to it as JSON lines so results survive restarts.
+type ExperimentRecorder struct {
+	// Pricing maps model names to prices for filling in RunRecord.Cost.
+	Pricing map[string]Pricing
+
+	mu    sync.Mutex
+	runs  []RunRecord
+	index map[string]int
+	file  *os.File
+}
+
+// experimentLogEntry is one line of the log file.
+type experimentLogEntry struct {
+	Run      *RunRecord     `json:"run,omitempty"`
+	Feedback *feedbackEntry `json:"feedback,omitempty"`
+}
+
+type feedbackEntry struct {
+	RunID   string  `json:"run_id"`
+	Score   float64 `json:"score"`
+	Comment string  `json:"comment,omitempty"`
+}
+
+// NewExperimentRecorder creates an in-memory recorder.
+func NewExperimentRecorder() *ExperimentRecorder {
+	return &ExperimentRecorder{index: make(map[string]int)}
+}
+
+// OpenExperimentLog loads the records in path, creating it if needed, and
+// appends new ones to it.
+func OpenExperimentLog(path string) (*ExperimentRecorder, error) {
+	r := NewExperimentRecorder()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening experiment log: %w", err)
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry experimentLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		switch {
+		case entry.Run != nil:
+			r.add(*entry.Run)
+		case entry.Feedback != nil:
+			r.applyFeedback(*entry.Feedback)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading experiment log: %w", err)
+	}
+
+	r.file = file
+	return r, nil
+}
+
+// Close closes the log file, if any.
+func (r *ExperimentRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Record stores a run, pricing it when Cost is unset. Each model's usage
+// is priced separately; models without pricing add nothing.
+func (r *ExperimentRecorder) Record(run RunRecord) error {
+	if run.Cost == 0 {
+		for _, m := range run.modelUsage() {
+			if price, ok := r.price(m.Model); ok {
+				run.Cost += price.Cost(m.Usage)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(run)
+	return r.write(experimentLogEntry{Run: &run})
+}
+
+// Feedback attaches a score and optional comment to a recorded run.
+func (r *ExperimentRecorder) Feedback(runID string, score float64, comment string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := feedbackEntry{RunID: runID, Score: score, Comment: comment}
+	if !r.applyFeedback(entry) {
+		return fmt.Errorf("run %s not found", runID)
+	}
+	return r.write(experimentLogEntry{Feedback: &entry})
+}
+
+// Runs returns the records for an experiment, or every record when
+// experiment is empty.
+func (r *ExperimentRecorder) Runs(experiment string) []RunRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var runs []RunRecord
+	for _, run := range r.runs {
+		if experiment == "" || run.Experiment == experiment {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// price looks up a model's pricing. Providers report dated snapshots such
+// as gpt-4o-2024-08-06, so the longest configured prefix wins when there is
+// no exact match.
+func (r *ExperimentRecorder) price(model string) (Pricing, bool) {
+	if price, ok := r.Pricing[model]; ok {
+		return price, true
+	}
+	best, found := "", false
+	for name := range r.Pricing {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best, found = name, true
+		}
+	}
+	return r.Pricing[best], found
+}
+
+func (r *ExperimentRecorder) add(run RunRecord) {
+	if r.index == nil {
+		r.index = make(map[string]int)
+	}
+	r.index[run.ID] = len(r.runs)
+	r.runs = append(r.runs, run)
+}
+
+func (r *ExperimentRecorder) applyFeedback(entry feedbackEntry) bool {
+	i, ok := r.index[entry.RunID]
+	if !ok {
+		return false
+	}
+	score := entry.Score
+	r.runs[i].Feedback = &score
+	r.runs[i].Comment = entry.Comment
+	return true
+}
+
+func (r *ExperimentRecorder) write(entry experimentLogEntry) error {
+	if r.file == nil {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing experiment log: %w", err)
+	}
+	return nil
+}
+
+// VariantReport summarises the runs served by one variant.
+type VariantReport struct {
+	Variant      string
+	Runs         int
+	ParseSuccess float64 // fraction of runs whose output parsed
+	MeanAttempts float64 // parse attempts per run, including corrections and, for CoTWorkFlow, every step
+	MeanLatency  time.Duration
+	P95Latency   time.Duration
+	MeanTokens   float64
+	TotalCost    float64
+	MeanCost     float64
+	FeedbackRuns int
+	MeanFeedback float64 // 0 when FeedbackRuns is 0
+}
+
+// Report compares the variants of an experiment, ordered by name.
+func (r *ExperimentRecorder) Report(experiment string) []VariantReport {
+	byVariant := make(map[string][]RunRecord)
+	for _, run := range r.Runs(experiment) {
+		byVariant[run.Variant] = append(byVariant[run.Variant], run)
+	}
+
+	reports := make([]VariantReport, 0, len(byVariant))
+	for variant, runs := range byVariant {
+		report := VariantReport{Variant: variant, Runs: len(runs)}
+		latencies := make([]time.Duration, len(runs))
+		var parsed, tokens, attempts int64
+		var latency time.Duration
+		var feedback float64
+		for i, run := range runs {
+			if run.ParseOK {
+				parsed++
+			}
+			attempts += int64(len(run.Attempts))
+			latencies[i] = run.Latency
+			latency += run.Latency
+			tokens += run.Usage.TotalTokens
+			report.TotalCost += run.Cost
+			if run.Feedback != nil {
+				report.FeedbackRuns++
+				feedback += *run.Feedback
+			}
+		}
+		n := float64(len(runs))
+		report.ParseSuccess = float64(parsed) / n
+		report.MeanAttempts = float64(attempts) / n
+		report.MeanLatency = latency / time.Duration(len(runs))
+		report.MeanTokens = float64(tokens) / n
+		report.MeanCost = report.TotalCost / n
+		if report.FeedbackRuns > 0 {
+			report.MeanFeedback = feedback / float64(report.FeedbackRuns)
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.P95Latency = latencies[int(math.Ceil(0.95*n))-1]
+		reports = append(reports, report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Variant < reports[j].Variant })
+	return reports
+}
+
+// FormatReport renders reports as a table.
+func FormatReport(reports []VariantReport) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VARIANT\tRUNS\tPARSED\tATTEMPTS\tMEAN LATENCY\tP95 LATENCY\tMEAN TOKENS\tMEAN COST\tFEEDBACK")
+	for _, r := range reports {
+		feedback := "-"
+		if r.FeedbackRuns > 0 {
+			feedback = fmt.Sprintf("%.2f (n=%d)", r.MeanFeedback, r.FeedbackRuns)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%.2f\t%s\t%s\t%.0f\t$%.4f\t%s\n",
+			r.Variant, r.Runs, 100*r.ParseSuccess, r.MeanAttempts,
+			r.MeanLatency.Round(time.Millisecond), r.P95Latency.Round(time.Millisecond),
+			r.MeanTokens, r.MeanCost, feedback)
+	}
+	w.Flush()
+	return b.String()
+}
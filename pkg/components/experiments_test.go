@@ -0,0 +1,164 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordPricesEachModel(t *testing.T) {
+	recorder := NewExperimentRecorder()
+	recorder.Pricing = map[string]Pricing{
+		"gpt-4o":  {PromptPerMillion: 2.5, CompletionPerMillion: 10},
+		"o3-mini": {PromptPerMillion: 1.1, CompletionPerMillion: 4.4},
+	}
+
+	step := NewRunRecord("step")
+	step.AddUsage("gpt-4o-2024-08-06", Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100})
+	run := NewRunRecord("flow")
+	run.AddRunUsage(step)
+	run.AddUsage("o3-mini", Usage{PromptTokens: 2000, CompletionTokens: 500, TotalTokens: 2500, ReasoningTokens: 300})
+	run.AddUsage("gpt-4o-2024-08-06", Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100})
+
+	if run.Model != "gpt-4o-2024-08-06" || len(run.Models) != 2 {
+		t.Fatalf("run models = %q, %+v", run.Model, run.Models)
+	}
+	if run.Usage.TotalTokens != 4700 || run.Models[0].Usage.TotalTokens != 2200 {
+		t.Errorf("usage = %+v, per model %+v", run.Usage, run.Models)
+	}
+	if err := recorder.Record(*run); err != nil {
+		t.Fatal(err)
+	}
+	want := (2000*2.5+200*10)/1e6 + (2000*1.1+500*4.4)/1e6
+	if got := recorder.Runs("")[0].Cost; math.Abs(got-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", got, want)
+	}
+}
+
+func TestRecordPricesRecordWithoutModels(t *testing.T) {
+	recorder := NewExperimentRecorder()
+	recorder.Pricing = map[string]Pricing{"gpt-4o": {PromptPerMillion: 2.5, CompletionPerMillion: 10}}
+	run := RunRecord{ID: "old", Model: "gpt-4o", Usage: Usage{PromptTokens: 1000, CompletionTokens: 100}}
+	if err := recorder.Record(run); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recorder.Runs("")[0].Cost, (1000*2.5+100*10)/1e6; math.Abs(got-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", got, want)
+	}
+}
+
+func TestExperimentAssign(t *testing.T) {
+	experiment := &Experiment{Name: "prompt", Variants: []PromptVariant{
+		{Name: "a", Weight: 3},
+		{Name: "b", Weight: 1},
+		{Name: "paused", Weight: 0},
+	}}
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		variant, err := experiment.Assign(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := experiment.Assign(key)
+		if again.Name != variant.Name {
+			t.Fatalf("key %s got %s, then %s", key, variant.Name, again.Name)
+		}
+		counts[variant.Name]++
+	}
+	if counts["paused"] != 0 {
+		t.Errorf("paused variant got %d runs", counts["paused"])
+	}
+	if share := float64(counts["a"]) / 4000; share < 0.7 || share > 0.8 {
+		t.Errorf("variant a got %.2f of traffic, want about 0.75", share)
+	}
+
+	for _, variants := range [][]PromptVariant{
+		nil,
+		{{Name: "off", Weight: 0}},
+		{{Name: "a", Weight: 1}, {Name: "bad", Weight: -1}},
+	} {
+		if _, err := (&Experiment{Name: "x", Variants: variants}).Assign("key"); err == nil {
+			t.Errorf("Assign with variants %+v succeeded", variants)
+		}
+	}
+}
+
+func TestExperimentLogRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "experiments.jsonl")
+	recorder, err := OpenExperimentLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := RunRecord{ID: "run-1", Experiment: "prompt", Variant: "a", ParseOK: true, Latency: time.Second}
+	if err := recorder.Record(run); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Record(RunRecord{ID: "run-2", Experiment: "other", Variant: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Feedback("run-1", 1, "accurate"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Feedback("missing", 1, ""); err == nil {
+		t.Error("feedback for an unknown run succeeded")
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenExperimentLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	runs := reopened.Runs("prompt")
+	if len(runs) != 1 || runs[0].ID != "run-1" {
+		t.Fatalf("reloaded runs = %+v", runs)
+	}
+	if runs[0].Feedback == nil || *runs[0].Feedback != 1 || runs[0].Comment != "accurate" {
+		t.Errorf("reloaded feedback = %v, %q", runs[0].Feedback, runs[0].Comment)
+	}
+	if got := len(reopened.Runs("")); got != 2 {
+		t.Errorf("reloaded %d runs in all, want 2", got)
+	}
+}
+
+func TestExperimentReport(t *testing.T) {
+	recorder := NewExperimentRecorder()
+	score := 1.0
+	runs := []RunRecord{
+		{ID: "1", Experiment: "prompt", Variant: "a", ParseOK: true, Latency: 100 * time.Millisecond, Usage: Usage{TotalTokens: 100}, Cost: 0.01, Attempts: make([]Attempt, 1), Feedback: &score},
+		{ID: "2", Experiment: "prompt", Variant: "a", ParseOK: false, Latency: 300 * time.Millisecond, Usage: Usage{TotalTokens: 300}, Cost: 0.03, Attempts: make([]Attempt, 3)},
+		{ID: "3", Experiment: "prompt", Variant: "b", ParseOK: true, Latency: 200 * time.Millisecond, Usage: Usage{TotalTokens: 50}, Attempts: make([]Attempt, 1)},
+		{ID: "4", Experiment: "other", Variant: "a"},
+	}
+	for _, run := range runs {
+		if err := recorder.Record(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reports := recorder.Report("prompt")
+	want := []VariantReport{
+		{Variant: "a", Runs: 2, ParseSuccess: 0.5, MeanAttempts: 2, MeanLatency: 200 * time.Millisecond, P95Latency: 300 * time.Millisecond, MeanTokens: 200, TotalCost: 0.04, MeanCost: 0.02, FeedbackRuns: 1, MeanFeedback: 1},
+		{Variant: "b", Runs: 1, ParseSuccess: 1, MeanAttempts: 1, MeanLatency: 200 * time.Millisecond, P95Latency: 200 * time.Millisecond, MeanTokens: 50},
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("Report =\n%+v\nwant\n%+v", reports, want)
+	}
+
+	// A variant without feedback must not stop the report being stored
+	if _, err := json.Marshal(reports); err != nil {
+		t.Errorf("marshalling the report: %v", err)
+	}
+	if table := FormatReport(reports); !strings.Contains(table, "1.00 (n=1)") {
+		t.Errorf("FormatReport does not show feedback:\n%s", table)
+	}
+}
@@ -0,0 +1,389 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON Schema keywords the grammar cannot express are ignored, so output
+// that matches the grammar is still validated by the parser. Objects only
+// generate the properties they declare, required ones first.
+
+// grammarPrimitives are the shared GBNF rules, emitted when referenced.
+// Whitespace between tokens is limited so a model cannot pad forever.
+var grammarPrimitives = map[string]string{
+	"space":            `| " " | "\n" [ \t]{0,20}`,
+	"boolean":          `("true" | "false") space`,
+	"null":             `"null" space`,
+	"integral-part":    `[0] | [1-9] [0-9]{0,15}`,
+	"decimal-part":     `[0-9]{1,16}`,
+	"integer":          `("-"? integral-part) space`,
+	"number":           `("-"? integral-part) ("." decimal-part)? ([eE] [-+]? integral-part)? space`,
+	"char":             `[^"\\\x7F\x00-\x1F] | [\\] (["\\bfnrt] | "u" [0-9a-fA-F]{4})`,
+	"string":           `"\"" char* "\"" space`,
+	"value":            `object | array | string | number | boolean | null`,
+	"object":           `"{" space ( string ":" space value ("," space string ":" space value)* )? "}" space`,
+	"array":            `"[" space ( value ("," space value)* )? "]" space`,
+	"date":             `[0-9]{4} "-" ( "0" [1-9] | "1" [0-2] ) "-" ( "0" [1-9] | [1-2] [0-9] | "3" [0-1] )`,
+	"time":             `( [01] [0-9] | "2" [0-3] ) ":" [0-5] [0-9] ":" [0-5] [0-9] ( "." [0-9]{1,9} )? ( "Z" | [+-] ( [01] [0-9] | "2" [0-3] ) ":" [0-5] [0-9] )`,
+	"date-string":      `"\"" date "\"" space`,
+	"time-string":      `"\"" time "\"" space`,
+	"date-time-string": `"\"" date "T" time "\"" space`,
+	"uuid-string":      `"\"" [0-9a-fA-F]{8} "-" [0-9a-fA-F]{4} "-" [0-9a-fA-F]{4} "-" [0-9a-fA-F]{4} "-" [0-9a-fA-F]{12} "\"" space`,
+}
+
+// grammarDependencies lists the primitives each primitive refers to.
+var grammarDependencies = map[string][]string{
+	"boolean":          {"space"},
+	"null":             {"space"},
+	"integer":          {"integral-part", "space"},
+	"number":           {"integral-part", "decimal-part", "space"},
+	"string":           {"char", "space"},
+	"value":            {"object", "array", "string", "number", "boolean", "null"},
+	"object":           {"string", "value", "space"},
+	"array":            {"value", "space"},
+	"date-string":      {"date", "space"},
+	"time-string":      {"time", "space"},
+	"date-time-string": {"date", "time", "space"},
+	"uuid-string":      {"space"},
+}
+
+// JSONSchemaToGBNF converts a JSON Schema document, such as one from
+// JSONSchemaBuilder.Build, into a llama.cpp GBNF grammar whose root rule
+// only accepts matching JSON. Supported keywords are type (including
+// lists of types), properties, required, items, enum, const, anyOf,
+// oneOf, minItems, maxItems, minLength, maxLength and the date, time,
+// date-time and uuid formats.
+func JSONSchemaToGBNF(schema map[string]interface{}) (string, error) {
+	g := newGrammar()
+	root, err := g.visit(schema, "root")
+	if err != nil {
+		return "", err
+	}
+	if root != "root" {
+		g.add("root", root)
+	}
+	return g.String(), nil
+}
+
+// GBNF returns a llama.cpp grammar for the builder's schema. See
+// JSONSchemaToGBNF.
+func (b *JSONSchemaBuilder) GBNF() (string, error) {
+	return JSONSchemaToGBNF(b.Build())
+}
+
+type grammar struct {
+	rules map[string]string
+	order []string
+}
+
+func newGrammar() *grammar {
+	return &grammar{rules: make(map[string]string)}
+}
+
+var ruleNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// add defines a rule, reusing an identical one or renaming on a clash, and
+// returns the name used.
+func (g *grammar) add(name, body string) string {
+	name = strings.Trim(ruleNameInvalid.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		name = "rule"
+	}
+	candidate := name
+	for i := 1; ; i++ {
+		existing, ok := g.rules[candidate]
+		if !ok {
+			break
+		}
+		if existing == body {
+			return candidate
+		}
+		candidate = name + strconv.Itoa(i)
+	}
+	g.rules[candidate] = body
+	g.order = append(g.order, candidate)
+	return candidate
+}
+
+// primitive adds a shared rule and the rules it depends on.
+func (g *grammar) primitive(name string) string {
+	if _, ok := g.rules[name]; ok {
+		return name
+	}
+	g.rules[name] = grammarPrimitives[name]
+	g.order = append(g.order, name)
+	for _, dep := range grammarDependencies[name] {
+		g.primitive(dep)
+	}
+	return name
+}
+
+func (g *grammar) String() string {
+	var b strings.Builder
+	for _, name := range g.order {
+		fmt.Fprintf(&b, "%s ::= %s\n", name, g.rules[name])
+	}
+	return b.String()
+}
+
+// visit returns a rule name or expression matching schema.
+func (g *grammar) visit(schema map[string]interface{}, name string) (string, error) {
+	if value, ok := schema["const"]; ok {
+		return g.add(name, jsonLiteral(value)+" "+g.primitive("space")), nil
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		alternatives := make([]string, len(enum))
+		for i, value := range enum {
+			alternatives[i] = jsonLiteral(value)
+		}
+		return g.add(name, "("+strings.Join(alternatives, " | ")+") "+g.primitive("space")), nil
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if options, ok := schema[key].([]interface{}); ok {
+			return g.alternatives(options, name)
+		}
+	}
+
+	switch typ := schema["type"].(type) {
+	case []interface{}:
+		options := make([]interface{}, len(typ))
+		for i, t := range typ {
+			option := make(map[string]interface{}, len(schema))
+			for k, v := range schema {
+				option[k] = v
+			}
+			option["type"] = t
+			options[i] = option
+		}
+		return g.alternatives(options, name)
+
+	case string:
+		switch typ {
+		case "object":
+			return g.object(schema, name)
+		case "array":
+			return g.array(schema, name)
+		case "string":
+			return g.str(schema, name), nil
+		case "integer", "number", "boolean", "null":
+			return g.primitive(typ), nil
+		}
+		return "", fmt.Errorf("grammar: unsupported type %q", typ)
+	}
+
+	if _, ok := schema["properties"]; ok {
+		return g.object(schema, name)
+	}
+	return g.primitive("value"), nil
+}
+
+func (g *grammar) alternatives(options []interface{}, name string) (string, error) {
+	refs := make([]string, 0, len(options))
+	for i, option := range options {
+		sub, ok := option.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("grammar: %s option %d is not a schema", name, i)
+		}
+		ref, err := g.visit(sub, name+"-"+strconv.Itoa(i))
+		if err != nil {
+			return "", err
+		}
+		refs = append(refs, ref)
+	}
+	return g.add(name, strings.Join(refs, " | ")), nil
+}
+
+func (g *grammar) str(schema map[string]interface{}, name string) string {
+	switch schema["format"] {
+	case "date", "time", "date-time", "uuid":
+		return g.primitive(schema["format"].(string) + "-string")
+	}
+	minLength, hasMin := schemaInt(schema, "minLength")
+	maxLength, hasMax := schemaInt(schema, "maxLength")
+	if !hasMin && !hasMax {
+		return g.primitive("string")
+	}
+	return g.add(name, `"\"" `+g.primitive("char")+repetition(minLength, maxLength, hasMax)+` "\"" `+g.primitive("space"))
+}
+
+func (g *grammar) array(schema map[string]interface{}, name string) (string, error) {
+	var item string
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		var err error
+		if item, err = g.visit(items, name+"-item"); err != nil {
+			return "", err
+		}
+	} else {
+		item = g.primitive("value")
+	}
+	space := g.primitive("space")
+	minItems, _ := schemaInt(schema, "minItems")
+	maxItems, hasMax := schemaInt(schema, "maxItems")
+
+	var list string
+	if !hasMax || maxItems > 0 {
+		list = item
+		if !hasMax || maxItems > 1 {
+			list += fmt.Sprintf(` ( "," %s %s )%s`, space, item, repetition(max(minItems-1, 0), maxItems-1, hasMax))
+		}
+		if minItems == 0 {
+			list = "( " + list + " )?"
+		}
+	}
+	return g.add(name, fmt.Sprintf(`"[" %s %s "]" %s`, space, list, space)), nil
+}
+
+func (g *grammar) object(schema map[string]interface{}, name string) (string, error) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	space := g.primitive("space")
+	if len(properties) == 0 {
+		if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+			return g.add(name, `"{" `+space+` "}" `+space), nil
+		}
+		return g.primitive("object"), nil
+	}
+
+	required := make(map[string]bool)
+	var order []string
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, key := range list {
+			if s, ok := key.(string); ok && properties[s] != nil && !required[s] {
+				required[s] = true
+				order = append(order, s)
+			}
+		}
+	} else if list, ok := schema["required"].([]string); ok {
+		for _, s := range list {
+			if properties[s] != nil && !required[s] {
+				required[s] = true
+				order = append(order, s)
+			}
+		}
+	}
+	var optional []string
+	for key := range properties {
+		if !required[key] {
+			optional = append(optional, key)
+		}
+	}
+	sort.Strings(optional)
+
+	pairs := make(map[string]string, len(properties))
+	for _, key := range append(append([]string{}, order...), optional...) {
+		sub, ok := properties[key].(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("grammar: property %s of %s is not a schema", key, name)
+		}
+		value, err := g.visit(sub, name+"-"+key)
+		if err != nil {
+			return "", err
+		}
+		pairs[key] = g.add(name+"-"+key+"-kv", fmt.Sprintf(`%s %s ":" %s %s`, jsonLiteral(key), space, space, value))
+	}
+
+	var body []string
+	for i, key := range order {
+		if i > 0 {
+			body = append(body, `"," `+space)
+		}
+		body = append(body, pairs[key])
+	}
+	if len(order) > 0 {
+		for _, key := range optional {
+			body = append(body, fmt.Sprintf(`( "," %s %s )?`, space, pairs[key]))
+		}
+	} else if len(optional) > 0 {
+		// Any subset of the optional properties, in order: the first one
+		// present starts the list and each later one is preceded by a comma
+		rest := ""
+		starts := make([]string, len(optional))
+		for i := len(optional) - 1; i >= 0; i-- {
+			starts[i] = strings.TrimSpace(pairs[optional[i]] + " " + rest)
+			if i == 0 {
+				break
+			}
+			rest = strings.TrimSpace(fmt.Sprintf(`( "," %s %s )? %s`, space, pairs[optional[i]], rest))
+			if i < len(optional)-1 {
+				rest = g.add(fmt.Sprintf("%s-rest-%d", name, i), rest)
+			}
+		}
+		body = append(body, "( "+strings.Join(starts, " | ")+" )?")
+	}
+	return g.add(name, fmt.Sprintf(`"{" %s %s "}" %s`, space, strings.Join(body, " "), space)), nil
+}
+
+// repetition is a GBNF quantifier for min to max repeats, or min or more
+// when bounded is false.
+func repetition(min, max int, bounded bool) string {
+	switch {
+	case !bounded && min == 0:
+		return "*"
+	case !bounded && min == 1:
+		return "+"
+	case !bounded:
+		return fmt.Sprintf("{%d,}", min)
+	case min == max:
+		return fmt.Sprintf("{%d}", min)
+	}
+	return fmt.Sprintf("{%d,%d}", min, max)
+}
+
+// schemaInt reads an integer keyword, which may be any numeric type
+// depending on where the schema came from.
+func schemaInt(schema map[string]interface{}, key string) (int, bool) {
+	switch v := schema[key].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case *int64:
+		if v != nil {
+			return int(*v), true
+		}
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// jsonLiteral is a GBNF string literal matching value's JSON encoding.
+func jsonLiteral(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data = []byte(fmt.Sprint(value))
+	}
+	return gbnfLiteral(string(data))
+}
+
+// gbnfLiteral quotes text as a GBNF string literal.
+func gbnfLiteral(text string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range text {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\x%02X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,42 @@
+package components
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaToGBNFGolden(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string"},
+			"risk":  map[string]interface{}{"type": "string", "enum": []interface{}{"low", "high"}},
+			"score": map[string]interface{}{"type": "integer"},
+			"tags": map[string]interface{}{
+				"type":     "array",
+				"items":    map[string]interface{}{"type": "string"},
+				"maxItems": 2,
+			},
+		},
+		"required": []interface{}{"name", "risk"},
+	}
+	got, err := JSONSchemaToGBNF(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/object.gbnf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(got) != strings.TrimSpace(string(want)) {
+		t.Errorf("grammar mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOutputFormatGBNFNoGrammar(t *testing.T) {
+	format := OutputFormat{Type: OutputJSON, Grammar: `root ::= "{}"`, NoGrammar: true}
+	if got, err := format.GBNF(); err != nil || got != "" {
+		t.Errorf("GBNF() = %q, %v; want no grammar", got, err)
+	}
+}
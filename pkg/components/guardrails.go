@@ -0,0 +1,367 @@
+package components
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// What a guardrail does when its check fails. The zero value rejects.
+const (
+	// GuardReject fails the result, which makes it eligible for correction.
+	GuardReject = "reject"
+	// GuardRepair fixes the result, for example by truncating or redacting.
+	GuardRepair = "repair"
+	// GuardAnnotate keeps the result unchanged and records the finding.
+	GuardAnnotate = "annotate"
+)
+
+// Guardrail checks a parsed result. It returns the result, changed if it
+// repaired anything, and what it found.
+type Guardrail interface {
+	Check(result interface{}) (interface{}, []GuardrailViolation)
+}
+
+// GuardrailViolation is one guardrail finding.
+type GuardrailViolation struct {
+	Guardrail string `json:"guardrail"`
+	// Path is a JSON Pointer to the offending value.
+	Path    string `json:"path"`
+	Action  string `json:"action"`
+	Message string `json:"message"`
+}
+
+func (v GuardrailViolation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s (%s)", path, v.Message, v.Guardrail)
+}
+
+// GuardrailError holds the rejecting violations from ApplyGuardrails.
+type GuardrailError struct {
+	Violations []GuardrailViolation
+}
+
+func (e *GuardrailError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
+	}
+	return "output failed guardrails: " + strings.Join(parts, "; ")
+}
+
+// ApplyGuardrails runs each guardrail in order, passing repaired results
+// along. It returns the final result and the repair and annotate findings;
+// any rejections are returned together as a *GuardrailError.
+func ApplyGuardrails(result interface{}, guardrails []Guardrail) (interface{}, []GuardrailViolation, error) {
+	var notes, rejected []GuardrailViolation
+	for _, g := range guardrails {
+		var violations []GuardrailViolation
+		result, violations = g.Check(result)
+		for _, v := range violations {
+			if v.Action == GuardReject {
+				rejected = append(rejected, v)
+			} else {
+				notes = append(notes, v)
+			}
+		}
+	}
+	if len(rejected) > 0 {
+		return result, notes, &GuardrailError{Violations: rejected}
+	}
+	return result, notes, nil
+}
+
+func guardAction(action string) string {
+	if action == "" {
+		return GuardReject
+	}
+	return action
+}
+
+// mapStrings calls fn for every string in value with its JSON Pointer and
+// returns value with the strings fn replaced.
+func mapStrings(value interface{}, path string, fn func(path, s string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fn(path, v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = mapStrings(item, path+"/"+escapePointer(key), fn)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = mapStrings(item, path+"/"+strconv.Itoa(i), fn)
+		}
+		return out
+	}
+	return value
+}
+
+// pointerMatches reports whether path is the field named by target, given
+// either as a JSON Pointer or as a top-level field name.
+func pointerMatches(target, path string) bool {
+	if !strings.HasPrefix(target, "/") {
+		target = "/" + escapePointer(target)
+	}
+	return path == target
+}
+
+// MaxLength limits string fields to a number of characters. Repair
+// truncates them.
+type MaxLength struct {
+	// Fields maps field names or JSON Pointers to their limits.
+	Fields map[string]int
+	Action string
+}
+
+func (g MaxLength) Check(result interface{}) (interface{}, []GuardrailViolation) {
+	var violations []GuardrailViolation
+	action := guardAction(g.Action)
+	result = mapStrings(result, "", func(path, s string) string {
+		for target, limit := range g.Fields {
+			if !pointerMatches(target, path) {
+				continue
+			}
+			if n := utf8.RuneCountInString(s); n > limit {
+				violations = append(violations, GuardrailViolation{
+					Guardrail: "max_length", Path: path, Action: action,
+					Message: fmt.Sprintf("is %d characters, limit is %d", n, limit),
+				})
+				if action == GuardRepair {
+					s = string([]rune(s)[:limit])
+				}
+			}
+		}
+		return s
+	})
+	return result, violations
+}
+
+// BannedPhrases flags strings containing any of Phrases, ignoring case.
+// Repair replaces each occurrence with "[removed]". Build it with
+// NewBannedPhrases so the phrases are compiled once; a literal compiles
+// them on every check.
+type BannedPhrases struct {
+	Phrases []string
+	Action  string
+
+	patterns []*regexp.Regexp // compiled Phrases, in order
+}
+
+// NewBannedPhrases creates a BannedPhrases guardrail with its phrases
+// compiled.
+func NewBannedPhrases(action string, phrases ...string) BannedPhrases {
+	return BannedPhrases{Phrases: phrases, Action: action, patterns: phrasePatterns(phrases)}
+}
+
+func phrasePatterns(phrases []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(phrases))
+	for i, phrase := range phrases {
+		patterns[i] = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(phrase))
+	}
+	return patterns
+}
+
+func (g BannedPhrases) Check(result interface{}) (interface{}, []GuardrailViolation) {
+	patterns := g.patterns
+	if len(patterns) != len(g.Phrases) {
+		patterns = phrasePatterns(g.Phrases)
+	}
+	var violations []GuardrailViolation
+	action := guardAction(g.Action)
+	result = mapStrings(result, "", func(path, s string) string {
+		for i, re := range patterns {
+			if !re.MatchString(s) {
+				continue
+			}
+			violations = append(violations, GuardrailViolation{
+				Guardrail: "banned_phrase", Path: path, Action: action,
+				Message: fmt.Sprintf("contains banned phrase %q", g.Phrases[i]),
+			})
+			if action == GuardRepair {
+				s = re.ReplaceAllString(s, "[removed]")
+			}
+		}
+		return s
+	})
+	return result, violations
+}
+
+// sensitivePattern is a kind of sensitive data and how to spot it.
+type sensitivePattern struct {
+	kind    string
+	pattern *regexp.Regexp
+	// valid filters out matches the pattern alone cannot rule out
+	valid func(match string) bool
+}
+
+var piiPatterns = []sensitivePattern{
+	{kind: "email address", pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{kind: "phone number", pattern: regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?\(?\b\d{3}\)?[\s.-]\d{3}[\s.-]\d{4}\b`)},
+	{kind: "US social security number", pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{kind: "payment card number", pattern: regexp.MustCompile(`\b(?:\d[ -]?){13,19}\b`), valid: luhn},
+}
+
+var secretPatterns = []sensitivePattern{
+	{kind: "AWS access key", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: "private key", pattern: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )?PRIVATE KEY-----`)},
+	{kind: "GitHub token", pattern: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{kind: "OpenAI API key", pattern: regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{20,}\b`)},
+	{kind: "Slack token", pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{kind: "JSON web token", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`)},
+	{kind: "credential assignment", pattern: regexp.MustCompile(`(?i)\b(?:api[_-]?key|secret|password|passwd|token)\b\s*[:=]\s*["']?[^\s"']{8,}`)},
+}
+
+// luhn checks a card number's checksum, which rules out most digit runs
+// that merely look like card numbers.
+func luhn(match string) bool {
+	var digits []int
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func checkSensitive(name string, patterns []sensitivePattern, action string, result interface{}) (interface{}, []GuardrailViolation) {
+	var violations []GuardrailViolation
+	action = guardAction(action)
+	result = mapStrings(result, "", func(path, s string) string {
+		for _, p := range patterns {
+			s = p.pattern.ReplaceAllStringFunc(s, func(match string) string {
+				if p.valid != nil && !p.valid(match) {
+					return match
+				}
+				violations = append(violations, GuardrailViolation{
+					Guardrail: name, Path: path, Action: action,
+					Message: "possible " + p.kind,
+				})
+				if action == GuardRepair {
+					return "[redacted " + p.kind + "]"
+				}
+				return match
+			})
+		}
+		return s
+	})
+	return result, violations
+}
+
+// PIIDetector flags email addresses, phone numbers, US social security
+// numbers and payment card numbers. Repair redacts them.
+type PIIDetector struct {
+	Action string
+}
+
+func (g PIIDetector) Check(result interface{}) (interface{}, []GuardrailViolation) {
+	return checkSensitive("pii", piiPatterns, g.Action, result)
+}
+
+// SecretDetector flags API keys, tokens, private keys and credential
+// assignments. Repair redacts them.
+type SecretDetector struct {
+	Action string
+}
+
+func (g SecretDetector) Check(result interface{}) (interface{}, []GuardrailViolation) {
+	return checkSensitive("secret", secretPatterns, g.Action, result)
+}
+
+// defaultCitation matches numbered references such as [1] and URLs.
+var defaultCitation = regexp.MustCompile(`\[\d+\]|https?://\S+`)
+
+// RequireCitations requires each of Fields to cite a source. Citations
+// are matched by Pattern, by default numbered references like [1] or
+// URLs. Missing citations cannot be repaired.
+type RequireCitations struct {
+	// Fields are field names or JSON Pointers of string fields.
+	Fields  []string
+	Pattern *regexp.Regexp
+	Action  string
+}
+
+func (g RequireCitations) Check(result interface{}) (interface{}, []GuardrailViolation) {
+	pattern := g.Pattern
+	if pattern == nil {
+		pattern = defaultCitation
+	}
+	action := guardAction(g.Action)
+	if action == GuardRepair {
+		action = GuardReject
+	}
+
+	var violations []GuardrailViolation
+	found := make(map[string]bool)
+	mapStrings(result, "", func(path, s string) string {
+		for _, target := range g.Fields {
+			if pointerMatches(target, path) {
+				found[target] = true
+				if !pattern.MatchString(s) {
+					violations = append(violations, GuardrailViolation{
+						Guardrail: "citations", Path: path, Action: action,
+						Message: "does not cite a source",
+					})
+				}
+			}
+		}
+		return s
+	})
+	for _, target := range g.Fields {
+		if !found[target] {
+			path := target
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + escapePointer(path)
+			}
+			violations = append(violations, GuardrailViolation{
+				Guardrail: "citations", Path: path, Action: action,
+				Message: "has no text to cite from",
+			})
+		}
+	}
+	return result, violations
+}
+
+// ValidatorFunc adapts a Go function to a Guardrail. A returned error
+// becomes a violation of the whole result; Func may also return a
+// modified result, which is used under GuardRepair.
+type ValidatorFunc struct {
+	Name   string
+	Func   func(result interface{}) (interface{}, error)
+	Action string
+}
+
+func (g ValidatorFunc) Check(result interface{}) (interface{}, []GuardrailViolation) {
+	repaired, err := g.Func(result)
+	if err == nil {
+		return result, nil
+	}
+	action := guardAction(g.Action)
+	violation := GuardrailViolation{Guardrail: g.Name, Action: action, Message: err.Error()}
+	if action == GuardRepair && repaired != nil {
+		return repaired, []GuardrailViolation{violation}
+	}
+	return result, []GuardrailViolation{violation}
+}
@@ -0,0 +1,37 @@
+package components
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBannedPhrases(t *testing.T) {
+	result := map[string]interface{}{
+		"summary": "This is Guaranteed to work.",
+		"items":   []interface{}{"fine", "totally risk-free"},
+	}
+	for _, guard := range []BannedPhrases{
+		NewBannedPhrases(GuardRepair, "guaranteed", "risk-free"),
+		{Phrases: []string{"guaranteed", "risk-free"}, Action: GuardRepair},
+	} {
+		repaired, violations := guard.Check(result)
+		want := map[string]interface{}{
+			"summary": "This is [removed] to work.",
+			"items":   []interface{}{"fine", "totally [removed]"},
+		}
+		if !reflect.DeepEqual(repaired, want) {
+			t.Errorf("repaired = %v, want %v", repaired, want)
+		}
+		if len(violations) != 2 {
+			t.Errorf("violations = %v", violations)
+		}
+	}
+	if result["summary"] != "This is Guaranteed to work." {
+		t.Error("Check modified its input")
+	}
+
+	_, _, err := ApplyGuardrails(result, []Guardrail{NewBannedPhrases("", "guaranteed")})
+	if err == nil {
+		t.Error("reject guardrail accepted a banned phrase")
+	}
+}
@@ -7,10 +7,22 @@ import (
 
 type LLMClient interface {
     Generate(ctx context.Context, prompt Prompt) (string, error)
+    // Complete returns every candidate produced for the prompt along with
+    // the provider's token usage.
+    Complete(ctx context.Context, prompt Prompt) (*Completion, error)
     GetModelInfo() ModelInfo
     ValidateResponse(response string) error
 }
 
+// StreamingClient is implemented by clients that can stream responses.
+type StreamingClient interface {
+    LLMClient
+    // Stream calls onDelta with each content fragment of the first choice
+    // as it arrives and returns the assembled completion once the response
+    // ends. An error from onDelta aborts the stream.
+    Stream(ctx context.Context, prompt Prompt, onDelta func(delta string) error) (*Completion, error)
+}
+
 type ModelInfo struct {
     Provider     string
     Model        string
@@ -25,5 +37,140 @@ type ClientConfig struct {
     MaxRetries   int
     Temperature  float64
     Model        string
-	MaxTokens    int64 
-}
\ No newline at end of file
+    MaxTokens    int64
+    // EmbeddingModel is used by clients that also implement Embedder.
+    EmbeddingModel string
+    // SendGrammar makes clients for OpenAI-compatible servers that accept
+    // a llama.cpp GBNF "grammar" field, such as llama.cpp's own server,
+    // send OutputFormat.GBNF with each request so local models can only
+    // produce output in the required format. OpenAI itself rejects it.
+    SendGrammar  bool
+    // Params holds the remaining client-wide sampling defaults. Temperature
+    // and MaxTokens set here take precedence over the fields above.
+    Params       GenerationParams
+}
+
+// GenerationParams holds sampling parameters for a single generation.
+// Unset (nil or empty) fields fall through to the next level, so a Prompt
+// overrides its WorkFlow, which overrides the ClientConfig.
+type GenerationParams struct {
+    Temperature      *float64
+    TopP             *float64
+    Seed             *int64
+    Stop             []string
+    PresencePenalty  *float64
+    FrequencyPenalty *float64
+    MaxTokens        *int64
+    N                *int64
+    // TopLogprobs, when set, asks for the log probability of each generated
+    // token and of that many likely alternatives (zero for none). Clients
+    // that support it report "logprobs" in ModelInfo.Capabilities.
+    TopLogprobs      *int64
+    // ReasoningEffort is "low", "medium" or "high" for reasoning models and
+    // is ignored by everything else.
+    ReasoningEffort  string
+}
+
+// Merge returns p with every field that is set in override replaced.
+func (p GenerationParams) Merge(override GenerationParams) GenerationParams {
+    if override.Temperature != nil {
+        p.Temperature = override.Temperature
+    }
+    if override.TopP != nil {
+        p.TopP = override.TopP
+    }
+    if override.Seed != nil {
+        p.Seed = override.Seed
+    }
+    if len(override.Stop) > 0 {
+        p.Stop = override.Stop
+    }
+    if override.PresencePenalty != nil {
+        p.PresencePenalty = override.PresencePenalty
+    }
+    if override.FrequencyPenalty != nil {
+        p.FrequencyPenalty = override.FrequencyPenalty
+    }
+    if override.MaxTokens != nil {
+        p.MaxTokens = override.MaxTokens
+    }
+    if override.N != nil {
+        p.N = override.N
+    }
+    if override.TopLogprobs != nil {
+        p.TopLogprobs = override.TopLogprobs
+    }
+    if override.ReasoningEffort != "" {
+        p.ReasoningEffort = override.ReasoningEffort
+    }
+    return p
+}
+
+// GenerationParams returns the client-wide defaults, folding the legacy
+// Temperature and MaxTokens fields into Params.
+func (c ClientConfig) GenerationParams() GenerationParams {
+    base := GenerationParams{Temperature: Float(c.Temperature)}
+    if c.MaxTokens > 0 {
+        base.MaxTokens = Int(c.MaxTokens)
+    }
+    return base.Merge(c.Params)
+}
+
+// Float returns a pointer to v, for use in GenerationParams.
+func Float(v float64) *float64 {
+    return &v
+}
+
+// Int returns a pointer to v, for use in GenerationParams.
+func Int(v int64) *int64 {
+    return &v
+}
+
+// Completion is a provider response with all of its candidates.
+type Completion struct {
+    Model   string
+    Choices []Choice
+    Usage   Usage
+}
+
+// Normalised finish reasons reported in Choice.FinishReason.
+const (
+    FinishReasonStop          = "stop"
+    FinishReasonLength        = "length"
+    FinishReasonContentFilter = "content_filter"
+    FinishReasonToolCalls     = "tool_calls"
+)
+
+// Choice is a single candidate generation.
+type Choice struct {
+    Content      string
+    FinishReason string
+    ToolCalls    []ToolCall
+    // Logprobs holds one entry per generated token when
+    // GenerationParams.TopLogprobs was set and the provider returned them.
+    Logprobs     []TokenLogprob
+}
+
+// TokenLogprob is a generated token, its natural log probability and the
+// most likely alternatives at that position.
+type TokenLogprob struct {
+    Token       string
+    Logprob     float64
+    TopLogprobs []TopLogprob
+}
+
+// TopLogprob is one alternative token at a position.
+type TopLogprob struct {
+    Token   string
+    Logprob float64
+}
+
+// Usage reports the tokens consumed by a request.
+type Usage struct {
+    PromptTokens     int64
+    CompletionTokens int64
+    TotalTokens      int64
+    // ReasoningTokens is the part of CompletionTokens spent on hidden
+    // reasoning by reasoning models.
+    ReasoningTokens  int64
+}
@@ -0,0 +1,139 @@
+package components
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerationParamsMerge(t *testing.T) {
+	base := GenerationParams{
+		Temperature:     Float(0.7),
+		TopP:            Float(0.9),
+		Stop:            []string{"END"},
+		MaxTokens:       Int(1000),
+		ReasoningEffort: "low",
+	}
+	tests := []struct {
+		name     string
+		base     GenerationParams
+		override GenerationParams
+		want     GenerationParams
+	}{
+		{name: "empty override keeps base", base: base, want: base},
+		{name: "empty base takes override", override: base, want: base},
+		{
+			name:     "set fields win, unset fields are kept",
+			base:     base,
+			override: GenerationParams{Temperature: Float(0.1), Seed: Int(7), ReasoningEffort: "high"},
+			want: GenerationParams{
+				Temperature:     Float(0.1),
+				TopP:            Float(0.9),
+				Seed:            Int(7),
+				Stop:            []string{"END"},
+				MaxTokens:       Int(1000),
+				ReasoningEffort: "high",
+			},
+		},
+		{
+			name:     "zero values that are set still override",
+			base:     base,
+			override: GenerationParams{Temperature: Float(0), MaxTokens: Int(0), TopLogprobs: Int(0)},
+			want: GenerationParams{
+				Temperature:     Float(0),
+				TopP:            Float(0.9),
+				Stop:            []string{"END"},
+				MaxTokens:       Int(0),
+				TopLogprobs:     Int(0),
+				ReasoningEffort: "low",
+			},
+		},
+		{
+			name:     "empty stop list keeps base",
+			base:     base,
+			override: GenerationParams{Stop: []string{}, PresencePenalty: Float(0.5), FrequencyPenalty: Float(0.2), N: Int(3)},
+			want: GenerationParams{
+				Temperature:      Float(0.7),
+				TopP:             Float(0.9),
+				Stop:             []string{"END"},
+				PresencePenalty:  Float(0.5),
+				FrequencyPenalty: Float(0.2),
+				MaxTokens:        Int(1000),
+				N:                Int(3),
+				ReasoningEffort:  "low",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.base.Merge(tt.override); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %s, want %s", compactJSON(got), compactJSON(tt.want))
+			}
+		})
+	}
+}
+
+func TestMergeDoesNotModifyReceiver(t *testing.T) {
+	base := GenerationParams{Temperature: Float(0.7)}
+	base.Merge(GenerationParams{Temperature: Float(0.1)})
+	if *base.Temperature != 0.7 {
+		t.Errorf("base temperature = %v after Merge, want 0.7", *base.Temperature)
+	}
+}
+
+// TestGenerationParamsPrecedence follows a request the way the client sees
+// it: client defaults, then the workflow's overrides, then the prompt's.
+func TestGenerationParamsPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   ClientConfig
+		workflow WorkflowConfig
+		prompt   GenerationParams
+		want     GenerationParams
+	}{
+		{
+			name:   "legacy client fields",
+			client: ClientConfig{Temperature: 0.3, MaxTokens: 500},
+			want:   GenerationParams{Temperature: Float(0.3), MaxTokens: Int(500)},
+		},
+		{
+			name:   "client params win over legacy fields",
+			client: ClientConfig{Temperature: 0.3, MaxTokens: 500, Params: GenerationParams{Temperature: Float(0.6)}},
+			want:   GenerationParams{Temperature: Float(0.6), MaxTokens: Int(500)},
+		},
+		{
+			name:     "workflow wins over client",
+			client:   ClientConfig{Temperature: 0.3, MaxTokens: 500},
+			workflow: WorkflowConfig{Params: GenerationParams{MaxTokens: Int(200), Seed: Int(1)}},
+			want:     GenerationParams{Temperature: Float(0.3), MaxTokens: Int(200), Seed: Int(1)},
+		},
+		{
+			name:     "legacy workflow temperature",
+			client:   ClientConfig{Temperature: 0.3},
+			workflow: WorkflowConfig{Temperature: 0.8},
+			want:     GenerationParams{Temperature: Float(0.8)},
+		},
+		{
+			name:     "workflow params win over its legacy temperature",
+			client:   ClientConfig{Temperature: 0.3},
+			workflow: WorkflowConfig{Temperature: 0.8, Params: GenerationParams{Temperature: Float(0.2)}},
+			want:     GenerationParams{Temperature: Float(0.2)},
+		},
+		{
+			name:     "prompt wins over workflow and client",
+			client:   ClientConfig{Temperature: 0.3, MaxTokens: 500},
+			workflow: WorkflowConfig{Params: GenerationParams{Temperature: Float(0.5), Seed: Int(1)}},
+			prompt:   GenerationParams{Temperature: Float(0), ReasoningEffort: "high"},
+			want:     GenerationParams{Temperature: Float(0), MaxTokens: Int(500), Seed: Int(1), ReasoningEffort: "high"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WorkFlow.Run merges the workflow into the prompt and the
+			// client merges the result into its defaults
+			request := tt.workflow.GenerationParams().Merge(tt.prompt)
+			if got := tt.client.GenerationParams().Merge(request); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("params = %s, want %s", compactJSON(got), compactJSON(tt.want))
+			}
+		})
+	}
+}
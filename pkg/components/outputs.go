@@ -12,12 +12,36 @@ type OutputParser interface {
 	ValidateSchema(schema interface{}) error
 }
 
-// SchemaField represents a single field in the JSON schema
+// SchemaField represents a single field in the JSON schema. Type is a JSON
+// Schema type: string, number, integer, boolean, object, array or null.
+// Objects describe their fields with Properties and arrays their elements
+// with Items; the remaining fields map onto the JSON Schema keywords of the
+// same name and are omitted when unset.
 type SchemaField struct {
 	Field       string `json:"field"`
 	Description string `json:"description"`
 	Type        string `json:"type"`
 	Required    bool   `json:"required"`
+
+	Properties []SchemaField `json:"properties,omitempty" yaml:"properties"`
+	Items      *SchemaField  `json:"items,omitempty" yaml:"items"`
+	// AdditionalProperties, when false, forbids object keys that are not
+	// listed in Properties.
+	AdditionalProperties *bool `json:"additionalProperties,omitempty" yaml:"additionalProperties"`
+
+	Enum    []interface{} `json:"enum,omitempty" yaml:"enum"`
+	Format  string        `json:"format,omitempty" yaml:"format"`
+	Pattern string        `json:"pattern,omitempty" yaml:"pattern"`
+
+	Minimum          *float64 `json:"minimum,omitempty" yaml:"minimum"`
+	Maximum          *float64 `json:"maximum,omitempty" yaml:"maximum"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty" yaml:"exclusiveMinimum"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty" yaml:"exclusiveMaximum"`
+	MinLength        *int64   `json:"minLength,omitempty" yaml:"minLength"`
+	MaxLength        *int64   `json:"maxLength,omitempty" yaml:"maxLength"`
+	MinItems         *int64   `json:"minItems,omitempty" yaml:"minItems"`
+	MaxItems         *int64   `json:"maxItems,omitempty" yaml:"maxItems"`
+	UniqueItems      bool     `json:"uniqueItems,omitempty" yaml:"uniqueItems"`
 }
 
 // JSONSchemaBuilder simplifies schema creation
@@ -27,6 +51,12 @@ type JSONSchemaBuilder struct {
 
 type JSONParser struct {
 	fields []SchemaField
+
+	// Strict disables RepairJSON, so only well-formed JSON is accepted.
+	Strict bool
+	// OnRepair, when set, is told what RepairJSON fixed in each input
+	// that needed it.
+	OnRepair func(repairs []string)
 }
 
 func NewJSONParser(fields []SchemaField) *JSONParser {
@@ -40,6 +70,14 @@ func (p *JSONParser) Parse(input string) (interface{}, error) {
 		return nil, errors.New("input must not be empty")
 	}
 
+	if !p.Strict {
+		var repairs []string
+		input, repairs = RepairJSON(input)
+		if len(repairs) > 0 && p.OnRepair != nil {
+			p.OnRepair(repairs)
+		}
+	}
+
 	// Parse into a map first
 	var result map[string]interface{}
 	if err := json.Unmarshal([]byte(input), &result); err != nil {
@@ -51,32 +89,111 @@ func (p *JSONParser) Parse(input string) (interface{}, error) {
 		return nil, fmt.Errorf("received schema definition instead of content. Please provide actual data")
 	}
 
-	// Validate all required fields are present
-	for _, field := range p.fields {
-		if field.Required {
-			if _, ok := result[field.Field]; !ok {
-				return nil, fmt.Errorf("missing required field: %s", field.Field)
-			}
-		}
+	if err := p.ValidateSchema(result); err != nil {
+		return nil, err
 	}
 
 	return result, nil
 }
 
-func (p *JSONParser) ValidateSchema(schema interface{}) error {
+// ValidateSchema checks a decoded JSON object, or raw JSON text, against
+// the parser's fields. Failures are returned as a *ValidationError listing
+// every violation.
+func (p *JSONParser) ValidateSchema(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		value = nil
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			return fmt.Errorf("failed to unmarshal JSON: %v", err)
+		}
+	case []byte:
+		value = nil
+		if err := json.Unmarshal(v, &value); err != nil {
+			return fmt.Errorf("failed to unmarshal JSON: %v", err)
+		}
+	}
+
+	if violations := ValidateFields(p.fields, value); len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
 	return nil
 }
 
-// Creates the actual JSON schema from the fields
+// Build creates a JSON Schema document describing an object with the
+// builder's fields as its properties.
 func (b *JSONSchemaBuilder) Build() map[string]interface{} {
-	properties := make(map[string]interface{})
+	return objectSchema(b.Fields, nil)
+}
 
-	for _, field := range b.Fields {
-		properties[field.Field] = map[string]interface{}{
-			"type":        field.Type,
-			"description": field.Description,
+// Schema returns the JSON Schema for the field's value.
+func (f SchemaField) Schema() map[string]interface{} {
+	var schema map[string]interface{}
+	if f.Type == "object" || len(f.Properties) > 0 {
+		schema = objectSchema(f.Properties, f.AdditionalProperties)
+	} else {
+		schema = map[string]interface{}{}
+		if f.Type != "" {
+			schema["type"] = f.Type
 		}
 	}
 
-	return properties
+	if f.Description != "" {
+		schema["description"] = f.Description
+	}
+	if f.Items != nil {
+		schema["items"] = f.Items.Schema()
+	}
+	if len(f.Enum) > 0 {
+		schema["enum"] = f.Enum
+	}
+	if f.Format != "" {
+		schema["format"] = f.Format
+	}
+	if f.Pattern != "" {
+		schema["pattern"] = f.Pattern
+	}
+	setIfNotNil(schema, "minimum", f.Minimum)
+	setIfNotNil(schema, "maximum", f.Maximum)
+	setIfNotNil(schema, "exclusiveMinimum", f.ExclusiveMinimum)
+	setIfNotNil(schema, "exclusiveMaximum", f.ExclusiveMaximum)
+	setIfNotNil(schema, "minLength", f.MinLength)
+	setIfNotNil(schema, "maxLength", f.MaxLength)
+	setIfNotNil(schema, "minItems", f.MinItems)
+	setIfNotNil(schema, "maxItems", f.MaxItems)
+	if f.UniqueItems {
+		schema["uniqueItems"] = true
+	}
+	return schema
+}
+
+// objectSchema describes an object with the given fields. An object
+// without fields accepts any keys, as the flat schemas always have.
+func objectSchema(fields []SchemaField, additional *bool) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object"}
+	if len(fields) == 0 {
+		return schema
+	}
+
+	properties := make(map[string]interface{}, len(fields))
+	required := []string{}
+	for _, field := range fields {
+		properties[field.Field] = field.Schema()
+		if field.Required {
+			required = append(required, field.Field)
+		}
+	}
+	schema["properties"] = properties
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if additional != nil {
+		schema["additionalProperties"] = *additional
+	}
+	return schema
+}
+
+func setIfNotNil[T any](schema map[string]interface{}, key string, value *T) {
+	if value != nil {
+		schema[key] = *value
+	}
 }
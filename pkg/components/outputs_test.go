@@ -0,0 +1,59 @@
+package components
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaBuilderGolden(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int64) *int64 { return &v }
+	closed := false
+	builder := &JSONSchemaBuilder{Fields: []SchemaField{
+		{Field: "domain", Type: "string", Required: true, Description: "The domain analyzed", Format: "hostname", MaxLength: n(253)},
+		{Field: "risk", Type: "string", Required: true, Enum: []interface{}{"low", "medium", "high"}},
+		{Field: "score", Type: "number", Minimum: f(0), ExclusiveMaximum: f(10)},
+		{Field: "owner", Type: "object", Required: true, AdditionalProperties: &closed, Properties: []SchemaField{
+			{Field: "name", Type: "string", Required: true, MinLength: n(1)},
+			{Field: "email", Type: "string", Format: "email"},
+		}},
+		{Field: "records", Type: "array", MinItems: n(1), MaxItems: n(5), Items: &SchemaField{
+			Type: "object",
+			Properties: []SchemaField{
+				{Field: "type", Type: "string", Required: true, Enum: []interface{}{"A", "MX"}},
+				{Field: "ttl", Type: "integer", Minimum: f(60), ExclusiveMinimum: f(0), Maximum: f(86400)},
+			},
+		}},
+		{Field: "tags", Type: "array", UniqueItems: true, Items: &SchemaField{Type: "string", Pattern: "^[a-z-]+$"}},
+		{Field: "notes"},
+	}}
+
+	got, err := json.MarshalIndent(builder.Build(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(got)) != strings.TrimSpace(string(want)) {
+		t.Errorf("schema mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestJSONSchemaBuilderFlatFields(t *testing.T) {
+	schema := (&JSONSchemaBuilder{}).Build()
+	if len(schema) != 1 || schema["type"] != "object" {
+		t.Errorf("Build() with no fields = %v, want an open object", schema)
+	}
+
+	schema = (&JSONSchemaBuilder{Fields: []SchemaField{{Field: "a", Type: "string"}}}).Build()
+	if _, ok := schema["required"]; ok {
+		t.Errorf("Build() = %v, want no required list when nothing is required", schema)
+	}
+	if _, ok := schema["additionalProperties"]; ok {
+		t.Errorf("Build() = %v, want the top level left open", schema)
+	}
+}
@@ -0,0 +1,303 @@
+package components
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output format types understood by OutputFormat and their parsers.
+const (
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+	OutputXML   = "xml"
+	OutputRegex = "regex"
+	// OutputJSONList is a JSON array of records and OutputJSONLines one
+	// record per line; both are parsed by RecordParser.
+	OutputJSONList  = "json_list"
+	OutputJSONLines = "jsonl"
+	// OutputClassification answers with one of OutputFormat.Labels.
+	OutputClassification = "classification"
+)
+
+// validateParsed checks a decoded result against fields.
+func validateParsed(fields []SchemaField, value interface{}) error {
+	if violations := ValidateFields(fields, value); len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// YAMLParser parses a YAML document into a map and validates it like
+// JSONParser. Code fences around the document are ignored.
+type YAMLParser struct {
+	fields []SchemaField
+}
+
+func NewYAMLParser(fields []SchemaField) *YAMLParser {
+	return &YAMLParser{fields: fields}
+}
+
+func (p *YAMLParser) Parse(input string) (interface{}, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, errors.New("input must not be empty")
+	}
+	if body, lang, ok := unfence(strings.TrimSpace(input)); ok && (lang == "" || lang == "yaml" || lang == "yml") {
+		input = body
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal([]byte(input), &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %v", err)
+	}
+	// Round trip through JSON so numbers and maps have the same types
+	// JSONParser produces
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("YAML is not representable as JSON: %v", err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("expected a YAML mapping: %v", err)
+	}
+
+	if err := p.ValidateSchema(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ValidateSchema checks a decoded result against the parser's fields.
+func (p *YAMLParser) ValidateSchema(value interface{}) error {
+	return validateParsed(p.fields, value)
+}
+
+// XMLTagParser reads each field from a section wrapped in a tag named
+// after it, such as <analysis>…</analysis>. Text outside the tags is
+// ignored, so the model may think aloud before answering. Object fields
+// hold nested tags, array fields hold <item> tags or "- " lines, and
+// scalar fields are converted to their schema type.
+type XMLTagParser struct {
+	fields []SchemaField
+}
+
+func NewXMLTagParser(fields []SchemaField) *XMLTagParser {
+	return &XMLTagParser{fields: fields}
+}
+
+func (p *XMLTagParser) Parse(input string) (interface{}, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, errors.New("input must not be empty")
+	}
+	result, err := tagSections(input, p.fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.ValidateSchema(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ValidateSchema checks a decoded result against the parser's fields.
+func (p *XMLTagParser) ValidateSchema(value interface{}) error {
+	return validateParsed(p.fields, value)
+}
+
+// tagSections extracts one value per field from text.
+func tagSections(text string, fields []SchemaField) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, field := range fields {
+		content, ok := tagContent(text, field.Field)
+		if !ok {
+			continue
+		}
+		value, err := tagValue(field, content)
+		if err != nil {
+			return nil, fmt.Errorf("section <%s>: %w", field.Field, err)
+		}
+		result[field.Field] = value
+	}
+	return result, nil
+}
+
+// tagPattern matches the opening and closing tags of one section name.
+type tagPattern struct {
+	open, close *regexp.Regexp
+}
+
+var tagPatterns sync.Map // string -> tagPattern
+
+// tagRegexps compiles the patterns for a tag name once; the names come
+// from schemas, so there are only ever a few.
+func tagRegexps(name string) tagPattern {
+	if cached, ok := tagPatterns.Load(name); ok {
+		return cached.(tagPattern)
+	}
+	pattern := tagPattern{
+		open:  regexp.MustCompile(`(?i)<` + regexp.QuoteMeta(name) + `(?:\s[^>]*)?>`),
+		close: regexp.MustCompile(`(?i)</` + regexp.QuoteMeta(name) + `\s*>`),
+	}
+	tagPatterns.Store(name, pattern)
+	return pattern
+}
+
+// tagContent returns the text inside the first <name> section. A section
+// left open at the end of the text, as in a truncated response, runs to
+// the end.
+func tagContent(text, name string) (string, bool) {
+	pattern := tagRegexps(name)
+	loc := pattern.open.FindStringIndex(text)
+	if loc == nil {
+		return "", false
+	}
+	rest := text[loc[1]:]
+	if end := pattern.close.FindStringIndex(rest); end != nil {
+		rest = rest[:end[0]]
+	}
+	return strings.TrimSpace(rest), true
+}
+
+func tagValue(field SchemaField, content string) (interface{}, error) {
+	switch {
+	case field.Type == "object" || len(field.Properties) > 0:
+		if len(field.Properties) == 0 {
+			// No declared structure, so expect JSON inside the tag
+			var value interface{}
+			if err := json.Unmarshal([]byte(content), &value); err != nil {
+				return nil, fmt.Errorf("expected a JSON object: %v", err)
+			}
+			return value, nil
+		}
+		return tagSections(content, field.Properties)
+
+	case field.Type == "array":
+		item := SchemaField{Type: "string"}
+		if field.Items != nil {
+			item = *field.Items
+		}
+		var items []string
+		if strings.Contains(strings.ToLower(content), "<item") {
+			itemClose := tagRegexps("item").close
+			for content != "" {
+				text, ok := tagContent(content, "item")
+				if !ok {
+					break
+				}
+				items = append(items, text)
+				next := itemClose.FindStringIndex(content)
+				if next == nil {
+					break
+				}
+				content = content[next[1]:]
+			}
+		} else {
+			for _, line := range strings.Split(content, "\n") {
+				line = strings.TrimSpace(line)
+				line = strings.TrimSpace(strings.TrimLeft(line, "-*•"))
+				if line != "" {
+					items = append(items, line)
+				}
+			}
+		}
+		values := make([]interface{}, 0, len(items))
+		for _, text := range items {
+			value, err := tagValue(item, text)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return coerceText(field.Type, content), nil
+}
+
+// coerceText converts text to the schema type when it parses as one, and
+// otherwise leaves it as a string for validation to report.
+func coerceText(typ, text string) interface{} {
+	switch typ {
+	case "number", "integer":
+		if n, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(strings.ToLower(strings.TrimSpace(text))); err == nil {
+			return b
+		}
+	case "null":
+		if strings.TrimSpace(text) == "" || strings.EqualFold(strings.TrimSpace(text), "null") {
+			return nil
+		}
+	}
+	return text
+}
+
+// RegexParser extracts fields from plain text with the named groups of a
+// regular expression. Each group fills the field of the same name and is
+// converted to that field's type. Fields without a group are left unset.
+type RegexParser struct {
+	pattern *regexp.Regexp
+	fields  []SchemaField
+}
+
+// NewRegexParser compiles pattern, which must have a named group for
+// every required field.
+func NewRegexParser(pattern string, fields []SchemaField) (*RegexParser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("compiling output pattern: %w", err)
+	}
+	groups := make(map[string]bool)
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			groups[name] = true
+		}
+	}
+	for _, field := range fields {
+		if field.Required && !groups[field.Field] {
+			return nil, fmt.Errorf("output pattern has no group for required field %s", field.Field)
+		}
+	}
+	return &RegexParser{pattern: re, fields: fields}, nil
+}
+
+func (p *RegexParser) Parse(input string) (interface{}, error) {
+	match := p.pattern.FindStringSubmatch(input)
+	if match == nil {
+		return nil, fmt.Errorf("response does not match the expected layout %s", p.pattern)
+	}
+
+	types := make(map[string]string, len(p.fields))
+	for _, field := range p.fields {
+		types[field.Field] = field.Type
+	}
+	result := make(map[string]interface{})
+	for i, name := range p.pattern.SubexpNames() {
+		if name == "" || i >= len(match) {
+			continue
+		}
+		// An empty group is an optional part of the layout that was left out
+		text := strings.TrimSpace(match[i])
+		if text == "" {
+			continue
+		}
+		result[name] = coerceText(types[name], text)
+	}
+
+	if err := p.ValidateSchema(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ValidateSchema checks a decoded result against the parser's fields.
+func (p *RegexParser) ValidateSchema(value interface{}) error {
+	return validateParsed(p.fields, value)
+}
@@ -0,0 +1,172 @@
+package components
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var reportFields = []SchemaField{
+	{Field: "risk", Type: "string", Required: true, Enum: []interface{}{"low", "medium", "high"}},
+	{Field: "score", Type: "number"},
+	{Field: "blocked", Type: "boolean"},
+	{Field: "hosts", Type: "array", Items: &SchemaField{Type: "string"}},
+}
+
+func TestYAMLParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:  "plain",
+			input: "risk: low\nscore: 3\nhosts:\n  - a.example.com\n  - b.example.com\n",
+			want:  map[string]interface{}{"risk": "low", "score": 3.0, "hosts": []interface{}{"a.example.com", "b.example.com"}},
+		},
+		{
+			name:  "yaml fence",
+			input: "```yaml\nrisk: high\nblocked: true\n```",
+			want:  map[string]interface{}{"risk": "high", "blocked": true},
+		},
+		{
+			name:  "bare fence",
+			input: "```\nrisk: medium\n```",
+			want:  map[string]interface{}{"risk": "medium"},
+		},
+		{name: "not a mapping", input: "- risk: low\n", wantErr: true},
+		{name: "invalid YAML", input: "risk: [low\n", wantErr: true},
+		{name: "fails validation", input: "risk: unknown\n", wantErr: true},
+		{name: "empty", input: "  ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewYAMLParser(reportFields).Parse(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXMLTagParser(t *testing.T) {
+	fields := append([]SchemaField{
+		{Field: "owner", Type: "object", Properties: []SchemaField{
+			{Field: "name", Type: "string", Required: true},
+			{Field: "verified", Type: "boolean"},
+		}},
+	}, reportFields...)
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:  "coerced scalars and thinking outside tags",
+			input: "Let me think about <this>.\n<risk>low</risk>\n<score> 4.5 </score>\n<blocked>False</blocked>",
+			want:  map[string]interface{}{"risk": "low", "score": 4.5, "blocked": false},
+		},
+		{
+			name:  "nested object tags",
+			input: "<risk>high</risk><owner>\n  <name>Example Ltd</name>\n  <verified>true</verified>\n</owner>",
+			want:  map[string]interface{}{"risk": "high", "owner": map[string]interface{}{"name": "Example Ltd", "verified": true}},
+		},
+		{
+			name:  "item tags",
+			input: "<risk>low</risk><hosts><item>a.example.com</item>\n<ITEM>b.example.com</ITEM></hosts>",
+			want:  map[string]interface{}{"risk": "low", "hosts": []interface{}{"a.example.com", "b.example.com"}},
+		},
+		{
+			name:  "dash lines",
+			input: "<risk>low</risk><hosts>\n- a.example.com\n* b.example.com\n\n</hosts>",
+			want:  map[string]interface{}{"risk": "low", "hosts": []interface{}{"a.example.com", "b.example.com"}},
+		},
+		{
+			name:  "tag attributes and case",
+			input: `<RISK confidence="high">medium</Risk>`,
+			want:  map[string]interface{}{"risk": "medium"},
+		},
+		{
+			name:  "truncated open tag runs to the end",
+			input: "<risk>low</risk><hosts>\n- a.example.com\n- b.exam",
+			want:  map[string]interface{}{"risk": "low", "hosts": []interface{}{"a.example.com", "b.exam"}},
+		},
+		{
+			name:    "uncoercible value is left for validation",
+			input:   "<risk>low</risk><score>about four</score>",
+			wantErr: true,
+		},
+		{name: "missing required section", input: "<score>1</score>", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewXMLTagParser(fields).Parse(tt.input)
+			if tt.wantErr {
+				var verr *ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("Parse = %v, %v; want a *ValidationError", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexParser(t *testing.T) {
+	parser, err := NewRegexParser(`(?s)Risk:\s*(?P<risk>\w+)\s*(?:Score:\s*(?P<score>[\d.]+))?`, reportFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{name: "all groups", input: "Risk: high\nScore: 7.5", want: map[string]interface{}{"risk": "high", "score": 7.5}},
+		{name: "optional group left out", input: "Sure. Risk: low", want: map[string]interface{}{"risk": "low"}},
+		{name: "no match", input: "I cannot tell.", wantErr: true},
+		{name: "fails validation", input: "Risk: extreme", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.Parse(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewRegexParser(`Score: (?P<score>\d+)`, reportFields); err == nil {
+		t.Error("NewRegexParser accepted a pattern without a group for the required risk field")
+	}
+	if _, err := NewRegexParser(`(?P<risk>`, reportFields); err == nil {
+		t.Error("NewRegexParser accepted an invalid pattern")
+	}
+}
@@ -1,6 +1,7 @@
 package components
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -11,36 +12,354 @@ type Prompt struct {
 	UserMessage   string
 	Variables     map[string]interface{}
 	Tools         *ToolList
-	OutputFormat  OutputFormat // Add explicit output format
+	OutputFormat  OutputFormat     // Add explicit output format
+	Params        GenerationParams // Per-prompt sampling overrides
+	Turns         []Message        // Extra turns sent after UserMessage
+	// ToolChoice enables native tool calling for Tools: "auto", "required",
+	// "none" or the name of a single tool. When empty the tools are only
+	// described in the system message by AddTools.
+	ToolChoice string
+	// AllowUnusedVariables lets Variables carry values the templates do not
+	// reference, e.g. state shared across the steps of a flow.
+	AllowUnusedVariables bool
+	// Examples are few-shot input/output pairs, presented according to
+	// ExampleMode (ExamplesAsTurns by default). When ExampleSelector is set
+	// only the examples it picks for the rendered user message are used.
+	Examples        []Example
+	ExampleMode     string
+	ExampleSelector ExampleSelector
+	// UntrustedVariables names variables holding external content such as
+	// tool output. Render wraps their values in randomised delimiters,
+	// tells the model to treat them as data and checks them against
+	// InjectionPatterns, handling matches per InjectionPolicy (InjectionFlag
+	// by default). Untrusted values are rendered as text, so use them as
+	// {{.name}} rather than ranging over them.
+	UntrustedVariables []string
+	InjectionPolicy    string
+	// InjectionFindings is set by Render to what the heuristics matched.
+	InjectionFindings []InjectionFinding
+
+	rendered bool
+}
+
+// Native tool calling modes for Prompt.ToolChoice.
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceRequired = "required"
+	ToolChoiceNone     = "none"
+)
+
+// Conversation roles understood by every LLMClient.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is a single conversation turn. Assistant turns may carry the
+// native tool calls they made, and tool turns answer one of them by ID.
+type Message struct {
+	Role       string
+	Content    string
+	ToolCalls  []ToolCall
+	ToolCallID string
 }
 
 type OutputFormat struct {
-	Type        string      // e.g., "json", "text"
+	Type        string      // e.g., "json", "yaml", "xml", "json_list", "jsonl", "regex", "classification", "text"
 	Schema      interface{} // for JSON schema definition
 	Description string      // human readable description
+	// Fields lists the expected fields in order. "xml" output uses them
+	// as its sections; "yaml" uses them when Schema is unset.
+	Fields []SchemaField
+	// Layout shows the plain-text layout a "regex" output must follow,
+	// for example "Risk: <low|medium|high>\nSummary: <one paragraph>".
+	Layout string
+	// Labels is the closed set a "classification" output chooses from.
+	Labels []string
+	// Grammar is a llama.cpp GBNF grammar sent to clients configured with
+	// SendGrammar. When empty, GBNF derives one from the format.
+	Grammar string
+	// NoGrammar makes GBNF return "". Continuation requests set it, since
+	// the grammar's root rule would make the model restart the document
+	// instead of finishing it.
+	NoGrammar bool
+}
+
+// Render returns a copy of the prompt with SystemMessage and UserMessage
+// executed as text/template against Variables and the output requirements
+// appended to the system message. Variables are referenced as {{.name}} (the
+// original {{name}} form still works) and the json, truncate, indent and
+// join helpers are available. Referencing a variable that was not supplied
+// is an error, as is supplying one that is never used unless
+// AllowUnusedVariables is set; names only tested by if/with may be absent.
+// Both messages are templates, so a literal {{, as in a JSON example in the
+// system message, is written {{"{{"}}; before templates it passed through
+// unchanged. The receiver is not modified, and rendering a rendered prompt
+// is a no-op.
+func (p Prompt) Render() (Prompt, error) {
+	return p.RenderContext(context.Background())
 }
 
-// FormatPrompt creates the final prompt with variables replaced and output requirements
-func (p *Prompt) FormatPrompt() (string, string) {
-	// Format system message with output requirements
-	systemMsg := p.SystemMessage
-	if p.OutputFormat.Type == "json" {
-		systemMsg += fmt.Sprintf("\nYou must return a JSON object in the following format. %s\n ONLY return raw JSON, no other text formatting", p.OutputFormat.Description)
-		if schema, ok := p.OutputFormat.Schema.(map[string]interface{}); ok {
+// RenderContext is Render with a context for the example selector.
+func (p Prompt) RenderContext(ctx context.Context) (Prompt, error) {
+	if p.rendered {
+		return p, nil
+	}
+
+	refs := newVariableRefs()
+	systemTmpl, err := parseTemplate("system", p.SystemMessage, refs)
+	if err != nil {
+		return p, err
+	}
+	userTmpl, err := parseTemplate("user", p.UserMessage, refs)
+	if err != nil {
+		return p, err
+	}
+	if err := checkVariables(refs, p.Variables, p.AllowUnusedVariables); err != nil {
+		return p, err
+	}
+
+	values, iso, err := isolateVariables(p.Variables, refs.used(p.UntrustedVariables), p.InjectionPolicy)
+	if err != nil {
+		return p, err
+	}
+
+	systemMsg, err := executeTemplate(systemTmpl, values)
+	if err != nil {
+		return p, err
+	}
+	userMsg, err := executeTemplate(userTmpl, values)
+	if err != nil {
+		return p, err
+	}
+	if iso != nil {
+		systemMsg += iso.instructions()
+		p.InjectionFindings = iso.findings
+	}
+
+	if p.ExampleSelector != nil && len(p.Examples) > 0 {
+		selected, err := p.ExampleSelector.Select(ctx, userMsg, p.Examples)
+		if err != nil {
+			return p, fmt.Errorf("selecting examples: %w", err)
+		}
+		p.Examples = selected
+	}
+	if p.ExampleMode == ExamplesInline {
+		systemMsg += exampleBlock(p.Examples)
+		p.Examples = nil
+	}
+
+	p.SystemMessage = systemMsg + p.OutputFormat.instructions()
+	p.UserMessage = userMsg
+	p.rendered = true
+	return p, nil
+}
+
+// FormatPrompt returns the rendered system and user messages without
+// modifying the prompt. See Render.
+func (p Prompt) FormatPrompt() (string, string, error) {
+	rendered, err := p.Render()
+	if err != nil {
+		return "", "", err
+	}
+	return rendered.SystemMessage, rendered.UserMessage, nil
+}
+
+// instructions describes the required output format to the model.
+func (f OutputFormat) instructions() string {
+	switch f.Type {
+	case OutputJSON:
+		text := fmt.Sprintf("\nYou must return a JSON object in the following format. %s\n ONLY return raw JSON, no other text formatting", f.Description)
+		if schema, ok := f.Schema.(map[string]interface{}); ok {
 			schemaStr, _ := json.MarshalIndent(schema, "", "  ")
-			systemMsg += fmt.Sprintf("\nUse this JSON schema: %s", string(schemaStr))
+			text += fmt.Sprintf("\nUse this JSON schema: %s", string(schemaStr))
 		}
+		return text
+
+	case OutputJSONList, OutputJSONLines:
+		text := fmt.Sprintf("\nYou must return a JSON array of records, one object per record. %s\n ONLY return raw JSON, no other text formatting", f.Description)
+		if f.Type == OutputJSONLines {
+			text = fmt.Sprintf("\nYou must return JSON Lines: one complete JSON object per line, one line per record, with no enclosing array. %s\n ONLY return the lines, no other text formatting", f.Description)
+		}
+		schema, ok := f.Schema.(map[string]interface{})
+		if !ok && len(f.Fields) > 0 {
+			schema, ok = (&JSONSchemaBuilder{Fields: f.Fields}).Build(), true
+		}
+		if ok {
+			schemaStr, _ := json.MarshalIndent(schema, "", "  ")
+			text += fmt.Sprintf("\nEvery record must match this JSON schema: %s", string(schemaStr))
+		}
+		return text
+
+	case OutputYAML:
+		text := fmt.Sprintf("\nYou must return a YAML document in the following format. %s\n ONLY return raw YAML, with no code fences or other text", f.Description)
+		schema, ok := f.Schema.(map[string]interface{})
+		if !ok && len(f.Fields) > 0 {
+			schema, ok = (&JSONSchemaBuilder{Fields: f.Fields}).Build(), true
+		}
+		if ok {
+			schemaStr, _ := json.MarshalIndent(schema, "", "  ")
+			text += fmt.Sprintf("\nThe document must match this JSON schema: %s", string(schemaStr))
+		}
+		return text
+
+	case OutputXML:
+		var b strings.Builder
+		fmt.Fprintf(&b, "\nStructure your response as the sections below, each wrapped in its own tag. %s", f.Description)
+		b.WriteString("\nYou may reason before the first section, but everything the answer needs must be inside the tags.\n")
+		writeTagSections(&b, f.Fields, "")
+		return b.String()
+
+	case OutputClassification:
+		var b strings.Builder
+		fmt.Fprintf(&b, "\nClassify the input. %s\nAnswer with exactly one of these labels, spelled as shown, and nothing else:\n", f.Description)
+		for _, label := range f.Labels {
+			b.WriteString("- " + label + "\n")
+		}
+		return b.String()
+
+	case OutputRegex:
+		text := fmt.Sprintf("\nRespond in plain text using exactly the layout below, replacing each <placeholder>. %s", f.Description)
+		if f.Layout != "" {
+			text += "\n\n" + f.Layout
+		}
+		return text
 	}
+	return ""
+}
 
-	// Replace variables in user message
-	userMsg := p.UserMessage
-	for key, value := range p.Variables {
-		placeholder := fmt.Sprintf("{{%s}}", key)
-		userMsg = strings.ReplaceAll(userMsg, placeholder, fmt.Sprintf("%v", value))
+// GBNF returns the grammar constraining output to this format: Grammar
+// when set, otherwise one derived from the schema of json, json_list and
+// jsonl outputs or the labels of classification output. It returns "" for
+// formats a grammar cannot describe and when NoGrammar is set.
+func (f OutputFormat) GBNF() (string, error) {
+	if f.NoGrammar {
+		return "", nil
 	}
-	p.SystemMessage = systemMsg
-	p.UserMessage = userMsg
-	return systemMsg, userMsg
+	if f.Grammar != "" {
+		return f.Grammar, nil
+	}
+	schema, ok := f.Schema.(map[string]interface{})
+	if !ok && len(f.Fields) > 0 {
+		schema, ok = (&JSONSchemaBuilder{Fields: f.Fields}).Build(), true
+	}
+
+	switch f.Type {
+	case OutputJSON:
+		if !ok {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		return JSONSchemaToGBNF(schema)
+
+	case OutputJSONList:
+		list := map[string]interface{}{"type": "array"}
+		if ok {
+			list["items"] = schema
+		}
+		return JSONSchemaToGBNF(list)
+
+	case OutputJSONLines:
+		if !ok {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		record, err := JSONSchemaToGBNF(schema)
+		if err != nil {
+			return "", err
+		}
+		// Rename the record's root so each line is one record, and keep
+		// records on one line
+		record = strings.Replace(record, "root ::=", "record ::=", 1)
+		record = strings.Replace(record, "space ::= "+grammarPrimitives["space"], `space ::= | " "`, 1)
+		return "root ::= (record \"\\n\")* record \"\\n\"?\n" + record, nil
+
+	case OutputClassification:
+		if len(f.Labels) == 0 {
+			return "", nil
+		}
+		labels := make([]string, len(f.Labels))
+		for i, label := range f.Labels {
+			labels[i] = gbnfLiteral(label)
+		}
+		return "root ::= " + strings.Join(labels, " | ") + "\n", nil
+	}
+	return "", nil
+}
+
+// writeTagSections describes XML sections, nesting tags for objects and
+// array items.
+func writeTagSections(b *strings.Builder, fields []SchemaField, indent string) {
+	for _, field := range fields {
+		hint := field.Description
+		if !field.Required {
+			hint = strings.TrimSpace(hint + " (optional)")
+		}
+		fmt.Fprintf(b, "%s<%s>\n", indent, field.Field)
+		switch {
+		case len(field.Properties) > 0:
+			if hint != "" {
+				fmt.Fprintf(b, "%s  %s\n", indent, hint)
+			}
+			writeTagSections(b, field.Properties, indent+"  ")
+		case field.Type == "array":
+			if hint != "" {
+				fmt.Fprintf(b, "%s  %s\n", indent, hint)
+			}
+			if field.Items != nil && len(field.Items.Properties) > 0 {
+				fmt.Fprintf(b, "%s  <item>\n", indent)
+				writeTagSections(b, field.Items.Properties, indent+"    ")
+				fmt.Fprintf(b, "%s  </item>\n", indent)
+			} else {
+				fmt.Fprintf(b, "%s  <item>%s</item>\n", indent, itemHint(field.Items))
+			}
+			fmt.Fprintf(b, "%s  ...\n", indent)
+		default:
+			text := strings.TrimSpace(hint + " " + scalarHint(&field))
+			if text == "" {
+				text = "..."
+			}
+			fmt.Fprintf(b, "%s  %s\n", indent, text)
+		}
+		fmt.Fprintf(b, "%s</%s>\n", indent, field.Field)
+	}
+}
+
+// itemHint describes one element of a simple array.
+func itemHint(items *SchemaField) string {
+	if items == nil {
+		return "..."
+	}
+	if text := strings.TrimSpace(items.Description + " " + scalarHint(items)); text != "" {
+		return text
+	}
+	return "..."
+}
+
+// scalarHint names a non-string type or the allowed values.
+func scalarHint(field *SchemaField) string {
+	switch {
+	case len(field.Enum) > 0:
+		values := make([]string, len(field.Enum))
+		for i, v := range field.Enum {
+			values[i] = fmt.Sprint(v)
+		}
+		return "(one of: " + strings.Join(values, ", ") + ")"
+	case field.Type != "" && field.Type != "string":
+		return "(" + field.Type + ")"
+	}
+	return ""
+}
+
+// Conversation returns the full message list to send: the system message,
+// any few-shot example turns, the user message and then any additional turns.
+func (p Prompt) Conversation() []Message {
+	messages := []Message{{Role: RoleSystem, Content: p.SystemMessage}}
+	if p.ExampleMode != ExamplesInline {
+		messages = append(messages, exampleTurns(p.Examples)...)
+	}
+	messages = append(messages, Message{Role: RoleUser, Content: p.UserMessage})
+	return append(messages, p.Turns...)
 }
 
 func (p *Prompt) AddTools() error {
@@ -51,9 +370,14 @@ func (p *Prompt) AddTools() error {
 	toolsDescription := "\nAvailable tools:\n"
 	for name, tool := range p.Tools.Tools {
 		toolsDescription += fmt.Sprintf("- %s: %s\n", name, tool.Description)
-		// Add tool inputs schema if available
-		if tool.Inputs != nil {
-			inputsJSON, err := json.MarshalIndent(tool.Inputs, "  ", "  ")
+		// Add tool inputs schema if available, falling back to the shape
+		// of the zero inputs for tools without one
+		inputs := interface{}(tool.Schema)
+		if tool.Schema == nil {
+			inputs = tool.Inputs
+		}
+		if inputs != nil {
+			inputsJSON, err := json.MarshalIndent(inputs, "  ", "  ")
 			if err != nil {
 				return fmt.Errorf("failed to marshal tool inputs: %w", err)
 			}
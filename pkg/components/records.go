@@ -0,0 +1,262 @@
+package components
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RejectedRecord is a record RecordParser dropped.
+type RejectedRecord struct {
+	// Index is the record's position in the response, counting from 0.
+	Index int `json:"index"`
+	// Line is the 1-based line of a JSON Lines record; 0 for arrays.
+	Line       int         `json:"line,omitempty"`
+	Raw        string      `json:"raw"`
+	Error      string      `json:"error"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// RecordParser parses a response holding many records, either a JSON
+// array of objects (OutputJSONList) or one object per line
+// (OutputJSONLines). Each record is validated on its own against fields,
+// and records that do not parse or validate are dropped rather than
+// failing the whole response. Parse returns a map with the valid records
+// under "records" and a []RejectedRecord under "rejected"; it only fails
+// when nothing usable came back.
+type RecordParser struct {
+	fields []SchemaField
+	lines  bool
+
+	// Strict disables RepairJSON, so only well-formed JSON is accepted.
+	Strict bool
+	// OnReject, when set, is told about the records each Parse dropped.
+	OnReject func(rejected []RejectedRecord)
+}
+
+// NewJSONListParser parses a JSON array of records. An object whose only
+// field is an array of objects, such as {"items": [...]}, is unwrapped
+// unless it is itself a valid record, and any other single object is
+// taken as one record. Violation paths in an unwrapped list start with
+// the wrapper's key, as in /items/0/name.
+func NewJSONListParser(fields []SchemaField) *RecordParser {
+	return &RecordParser{fields: fields}
+}
+
+// NewJSONLinesParser parses newline-delimited JSON records. Blank lines and
+// lines without any JSON, such as a leading sentence, are skipped. A
+// response that is one JSON value spread over several lines, such as a
+// single pretty-printed record, is parsed as a whole like JSON list output;
+// several pretty-printed records are read line by line and rejected.
+func NewJSONLinesParser(fields []SchemaField) *RecordParser {
+	return &RecordParser{fields: fields, lines: true}
+}
+
+func (p *RecordParser) Parse(input string) (interface{}, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, errors.New("input must not be empty")
+	}
+
+	var records []interface{}
+	var rejected []RejectedRecord
+	var err error
+	switch {
+	case p.lines && p.singleDocument(input):
+		records, rejected, err = p.parseList(unfencedText(input))
+	case p.lines && !p.looksLikeArray(input):
+		records, rejected = p.parseLines(input)
+	default:
+		records, rejected, err = p.parseList(input)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 && len(rejected) > 0 {
+		// Nothing survived, so report every record's problems for correction
+		verr := &ValidationError{}
+		for _, r := range rejected {
+			if len(r.Violations) > 0 {
+				verr.Violations = append(verr.Violations, r.Violations...)
+			} else {
+				verr.Violations = append(verr.Violations, Violation{Path: "/" + strconv.Itoa(r.Index), Keyword: "type", Message: r.Error})
+			}
+		}
+		return nil, verr
+	}
+	if len(rejected) > 0 && p.OnReject != nil {
+		p.OnReject(rejected)
+	}
+	if records == nil {
+		records = []interface{}{}
+	}
+	return map[string]interface{}{"records": records, "rejected": rejected}, nil
+}
+
+// ValidateSchema checks one record, or every element of a []interface{},
+// against the parser's fields.
+func (p *RecordParser) ValidateSchema(value interface{}) error {
+	items, ok := value.([]interface{})
+	if !ok {
+		return validateParsed(p.fields, value)
+	}
+	var violations []Violation
+	for i, item := range items {
+		for _, v := range ValidateFields(p.fields, item) {
+			v.Path = "/" + strconv.Itoa(i) + v.Path
+			violations = append(violations, v)
+		}
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// looksLikeArray reports whether JSON Lines output came back as an array
+// instead, which is accepted.
+func (p *RecordParser) looksLikeArray(input string) bool {
+	return strings.HasPrefix(unfencedText(input), "[")
+}
+
+// singleDocument reports whether JSON Lines output is one JSON value over
+// several lines, which splitting into lines would break apart.
+func (p *RecordParser) singleDocument(input string) bool {
+	text := unfencedText(input)
+	return strings.Contains(text, "\n") && json.Valid([]byte(text))
+}
+
+func unfencedText(input string) string {
+	text := strings.TrimSpace(input)
+	if body, _, ok := unfence(text); ok {
+		text = strings.TrimSpace(body)
+	}
+	return text
+}
+
+func (p *RecordParser) parseList(input string) ([]interface{}, []RejectedRecord, error) {
+	if !p.Strict {
+		input, _ = RepairJSON(input)
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(input), &value); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+	}
+
+	var items []interface{}
+	path := ""
+	switch v := value.(type) {
+	case []interface{}:
+		items = v
+	case map[string]interface{}:
+		items = []interface{}{v}
+		if key, list, ok := wrappedList(v); ok && len(ValidateFields(p.fields, v)) > 0 {
+			items = list
+			path = "/" + escapePointer(key)
+		}
+	default:
+		return nil, nil, fmt.Errorf("expected a JSON array of records, got %s", jsonType(value))
+	}
+
+	var records []interface{}
+	var rejected []RejectedRecord
+	for i, item := range items {
+		if r, ok := p.check(path, i, item); !ok {
+			rejected = append(rejected, r)
+			continue
+		}
+		records = append(records, item)
+	}
+	return records, rejected, nil
+}
+
+// wrappedList returns the key and array of an object whose only field is
+// a list of objects, such as {"items": [{...}, {...}]}.
+func wrappedList(object map[string]interface{}) (string, []interface{}, bool) {
+	if len(object) != 1 {
+		return "", nil, false
+	}
+	for key, inner := range object {
+		list, ok := inner.([]interface{})
+		if !ok {
+			return "", nil, false
+		}
+		for _, item := range list {
+			if _, ok := item.(map[string]interface{}); !ok {
+				return "", nil, false
+			}
+		}
+		return key, list, true
+	}
+	return "", nil, false
+}
+
+var jsonLineStart = regexp.MustCompile(`^\s*[{\[]`)
+
+func (p *RecordParser) parseLines(input string) ([]interface{}, []RejectedRecord) {
+	var records []interface{}
+	var rejected []RejectedRecord
+	index := 0
+	for n, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if !jsonLineStart.MatchString(line) {
+			// Blank, a code fence or prose around the records
+			continue
+		}
+
+		var value interface{}
+		err := json.Unmarshal([]byte(line), &value)
+		if err != nil && !p.Strict {
+			repaired, repairs := RepairJSON(line)
+			if cutOff(repairs) {
+				// Most likely the response hit its length limit mid-record
+				err = errors.New("record is cut off")
+			} else {
+				err = json.Unmarshal([]byte(repaired), &value)
+			}
+		}
+		if err != nil {
+			rejected = append(rejected, RejectedRecord{Index: index, Line: n + 1, Raw: line, Error: fmt.Sprintf("invalid JSON: %v", err)})
+			index++
+			continue
+		}
+
+		if r, ok := p.check("", index, value); !ok {
+			r.Line = n + 1
+			rejected = append(rejected, r)
+		} else {
+			records = append(records, value)
+		}
+		index++
+	}
+	return records, rejected
+}
+
+// cutOff reports whether RepairJSON had to close an unfinished record.
+func cutOff(repairs []string) bool {
+	for _, r := range repairs {
+		if r == RepairTruncated || r == RepairUnterminated {
+			return true
+		}
+	}
+	return false
+}
+
+// check validates one record, returning why it was rejected if it was.
+// Violation paths start with path, the pointer to the list holding the
+// record.
+func (p *RecordParser) check(path string, index int, item interface{}) (RejectedRecord, bool) {
+	violations := ValidateFields(p.fields, item)
+	if len(violations) == 0 {
+		return RejectedRecord{}, true
+	}
+	prefix := path + "/" + strconv.Itoa(index)
+	for i := range violations {
+		violations[i].Path = prefix + violations[i].Path
+	}
+	err := &ValidationError{Violations: violations}
+	return RejectedRecord{Index: index, Raw: compactJSON(item), Error: err.Error(), Violations: violations}, false
+}
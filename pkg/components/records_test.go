@@ -0,0 +1,141 @@
+package components
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestJSONListParser(t *testing.T) {
+	fields := []SchemaField{
+		{Field: "name", Type: "string", Required: true},
+		{Field: "tags", Type: "array", Items: &SchemaField{Type: "string"}},
+	}
+	tests := []struct {
+		name     string
+		input    string
+		records  int
+		rejected int
+	}{
+		{"array", `[{"name": "a"}, {"name": "b"}]`, 2, 0},
+		{"invalid record dropped", `[{"name": "a"}, {"tags": []}]`, 1, 1},
+		{"wrapped list", `{"items": [{"name": "a"}, {"name": "b"}]}`, 2, 0},
+		{"empty wrapped list", `{"items": []}`, 0, 0},
+		{"single record", `{"name": "a"}`, 1, 0},
+		{"single record with only an array field", `{"tags": ["a", "b"]}`, 0, 1},
+		{"fenced", "```json\n[{\"name\": \"a ```x``` b\"}]\n```", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewJSONListParser(fields).Parse(tt.input)
+			if tt.records == 0 && tt.rejected > 0 {
+				var verr *ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("Parse error = %v, want a *ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := result.(map[string]interface{})
+			if got := len(out["records"].([]interface{})); got != tt.records {
+				t.Errorf("records = %d, want %d", got, tt.records)
+			}
+			if got := len(out["rejected"].([]RejectedRecord)); got != tt.rejected {
+				t.Errorf("rejected = %d, want %d", got, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestJSONListParserKeepsArrayFieldRecord(t *testing.T) {
+	// A record whose only field is an array is one record, not a list
+	fields := []SchemaField{{Field: "tags", Type: "array", Required: true, Items: &SchemaField{Type: "string"}}}
+	result, err := NewJSONListParser(fields).Parse(`{"tags": ["a", "b"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{map[string]interface{}{"tags": []interface{}{"a", "b"}}}
+	if got := result.(map[string]interface{})["records"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+
+	// The same holds for a list of objects that is itself a valid record
+	fields = []SchemaField{{Field: "items", Type: "array", Required: true}}
+	result, err = NewJSONListParser(fields).Parse(`{"items": [{"x": 1}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(result.(map[string]interface{})["records"].([]interface{})); got != 1 {
+		t.Errorf("records = %d, want the object as 1 record", got)
+	}
+}
+
+func TestJSONLinesParser(t *testing.T) {
+	fields := []SchemaField{{Field: "name", Type: "string", Required: true}}
+	input := "Here you go:\n{\"name\": \"a\"}\n\n{\"nope\": 1}\n{\"name\": \"b\"}\n{\"name\": \"c"
+	result, err := NewJSONLinesParser(fields).Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := result.(map[string]interface{})
+	if got := len(out["records"].([]interface{})); got != 2 {
+		t.Errorf("records = %d, want 2", got)
+	}
+	rejected := out["rejected"].([]RejectedRecord)
+	if len(rejected) != 2 || rejected[0].Line != 4 || rejected[1].Line != 6 {
+		t.Errorf("rejected = %+v, want lines 4 and 6", rejected)
+	}
+}
+
+func TestJSONListParserWrappedListPaths(t *testing.T) {
+	fields := []SchemaField{{Field: "name", Type: "string", Required: true}}
+	result, err := NewJSONListParser(fields).Parse(`{"items": [{"name": "a"}, {"name": 1}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected := result.(map[string]interface{})["rejected"].([]RejectedRecord)
+	if len(rejected) != 1 || len(rejected[0].Violations) != 1 || rejected[0].Violations[0].Path != "/items/1/name" {
+		t.Errorf("rejected = %+v, want a violation at /items/1/name", rejected)
+	}
+
+	_, err = NewJSONListParser(fields).Parse(`{"a/b": [{"name": 1}]}`)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Violations[0].Path != "/a~1b/0/name" {
+		t.Errorf("Parse error = %v, want a violation at /a~1b/0/name", err)
+	}
+}
+
+func TestJSONLinesParserPrettyPrintedRecord(t *testing.T) {
+	fields := []SchemaField{
+		{Field: "name", Type: "string", Required: true},
+		{Field: "tags", Type: "array", Items: &SchemaField{Type: "string"}},
+	}
+	input := "```json\n{\n  \"name\": \"a\",\n  \"tags\": [\n    \"x\"\n  ]\n}\n```"
+	for _, strict := range []bool{false, true} {
+		parser := NewJSONLinesParser(fields)
+		parser.Strict = strict
+		result, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("strict %v: %v", strict, err)
+		}
+		want := []interface{}{map[string]interface{}{"name": "a", "tags": []interface{}{"x"}}}
+		if got := result.(map[string]interface{})["records"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("strict %v: records = %v, want %v", strict, got, want)
+		}
+	}
+
+	_, err := NewJSONLinesParser(fields).Parse("{\n  \"tags\": [\"x\"]\n}")
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Violations) != 1 || verr.Violations[0].Path != "/0/name" {
+		t.Errorf("Parse error = %v, want one violation at /0/name", err)
+	}
+
+	// Several pretty-printed records are not one document, so they are
+	// read line by line and every fragment is rejected
+	_, err = NewJSONLinesParser(fields).Parse("{\n  \"name\": \"a\"\n}\n{\n  \"name\": \"b\"\n}")
+	if !errors.As(err, &verr) || len(verr.Violations) != 2 {
+		t.Errorf("Parse error = %v, want both opening lines rejected", err)
+	}
+}
@@ -0,0 +1,254 @@
+package components
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// VariableError reports prompt variables that a template references but
+// were not supplied, or that were supplied but never referenced.
+type VariableError struct {
+	Missing []string
+	Unused  []string
+}
+
+func (e *VariableError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing variables: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Unused) > 0 {
+		parts = append(parts, "unused variables: "+strings.Join(e.Unused, ", "))
+	}
+	return "prompt " + strings.Join(parts, "; ")
+}
+
+// templateFuncs are the helpers available to prompt templates. They take
+// the piped value last so they read naturally: {{.history | truncate 500}}.
+var templateFuncs = template.FuncMap{
+	"json":     templateJSON,
+	"truncate": templateTruncate,
+	"indent":   templateIndent,
+	"join":     templateJoin,
+}
+
+func templateJSON(v interface{}) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func templateTruncate(n int, v interface{}) string {
+	s := fmt.Sprint(v)
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
+
+func templateIndent(n int, v interface{}) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(fmt.Sprint(v), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func templateJoin(sep string, v interface{}) string {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return fmt.Sprint(v)
+	}
+	items := make([]string, value.Len())
+	for i := range items {
+		items[i] = fmt.Sprint(value.Index(i).Interface())
+	}
+	return strings.Join(items, sep)
+}
+
+// legacyPlaceholder matches the original {{name}} placeholder syntax.
+var legacyPlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// templateWords are identifiers that mean something to text/template on
+// their own and so are never treated as legacy placeholders.
+var templateWords = map[string]bool{
+	"end": true, "else": true, "nil": true, "true": true, "false": true,
+	"break": true, "continue": true, "and": true, "or": true, "not": true,
+	"len": true, "index": true, "print": true, "printf": true, "println": true,
+	"html": true, "js": true, "urlquery": true, "eq": true, "ne": true,
+	"lt": true, "le": true, "gt": true, "ge": true, "slice": true, "call": true,
+}
+
+// upgradePlaceholders rewrites {{name}} to {{.name}} so prompts written for
+// the original string replacement keep working.
+func upgradePlaceholders(text string) string {
+	return legacyPlaceholder.ReplaceAllStringFunc(text, func(match string) string {
+		name := legacyPlaceholder.FindStringSubmatch(match)[1]
+		if templateWords[name] || templateFuncs[name] != nil {
+			return match
+		}
+		return "{{." + name + "}}"
+	})
+}
+
+// variableRefs records which top-level variables a template uses. Names
+// tested by an if/with condition are optional, both in the condition and in
+// the block it guards; everything else must be supplied.
+type variableRefs struct {
+	required map[string]bool
+	optional map[string]bool
+}
+
+func newVariableRefs() *variableRefs {
+	return &variableRefs{required: map[string]bool{}, optional: map[string]bool{}}
+}
+
+// used filters names down to those the templates reference.
+func (r *variableRefs) used(names []string) []string {
+	var result []string
+	for _, name := range names {
+		if r.required[name] || r.optional[name] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func (r *variableRefs) walk(node parse.Node, root bool, guarded map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			r.walk(child, root, guarded)
+		}
+	case *parse.ActionNode:
+		r.walkPipe(n.Pipe, root, guarded, nil)
+	case *parse.IfNode:
+		inner := r.guard(n.Pipe, root, guarded)
+		r.walk(n.List, root, inner)
+		r.walk(n.ElseList, root, guarded)
+	case *parse.RangeNode:
+		r.walkPipe(n.Pipe, root, guarded, nil)
+		r.walk(n.List, false, guarded)
+		r.walk(n.ElseList, root, guarded)
+	case *parse.WithNode:
+		inner := r.guard(n.Pipe, root, guarded)
+		r.walk(n.List, false, inner)
+		r.walk(n.ElseList, root, guarded)
+	case *parse.TemplateNode:
+		r.walkPipe(n.Pipe, root, guarded, nil)
+	}
+}
+
+// guard records the names a condition tests and returns the guarded set
+// for the block it controls.
+func (r *variableRefs) guard(pipe *parse.PipeNode, root bool, guarded map[string]bool) map[string]bool {
+	tested := map[string]bool{}
+	r.walkPipe(pipe, root, guarded, tested)
+	inner := make(map[string]bool, len(guarded)+len(tested))
+	for name := range guarded {
+		inner[name] = true
+	}
+	for name := range tested {
+		inner[name] = true
+	}
+	return inner
+}
+
+// walkPipe records the names used in a pipeline. When tested is non-nil
+// the pipeline is a condition and its names are collected there.
+func (r *variableRefs) walkPipe(pipe *parse.PipeNode, root bool, guarded map[string]bool, tested map[string]bool) {
+	if pipe == nil {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			r.walkArg(arg, root, guarded, tested)
+		}
+	}
+}
+
+func (r *variableRefs) walkArg(arg parse.Node, root bool, guarded map[string]bool, tested map[string]bool) {
+	name := ""
+	switch a := arg.(type) {
+	case *parse.FieldNode:
+		if root {
+			name = a.Ident[0]
+		}
+	case *parse.VariableNode:
+		if a.Ident[0] == "$" && len(a.Ident) > 1 {
+			name = a.Ident[1]
+		}
+	case *parse.ChainNode:
+		r.walkArg(a.Node, root, guarded, tested)
+	case *parse.PipeNode:
+		r.walkPipe(a, root, guarded, tested)
+	}
+
+	switch {
+	case name == "":
+	case tested != nil:
+		tested[name] = true
+		r.optional[name] = true
+	case guarded[name]:
+		r.optional[name] = true
+	default:
+		r.required[name] = true
+	}
+}
+
+// parseTemplate parses text as a template and records the variables it uses.
+func parseTemplate(name string, text string, refs *variableRefs) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(upgradePlaceholders(text))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s template: %w (write a literal {{ as {{\"{{\"}})", name, err)
+	}
+	refs.walk(tmpl.Tree.Root, true, nil)
+	return tmpl, nil
+}
+
+func executeTemplate(tmpl *template.Template, variables map[string]interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, variables); err != nil {
+		return "", fmt.Errorf("rendering %s template: %w", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// checkVariables compares what the templates used against what was supplied.
+func checkVariables(refs *variableRefs, variables map[string]interface{}, allowUnused bool) error {
+	varErr := &VariableError{}
+	for name := range refs.required {
+		if _, ok := variables[name]; !ok {
+			varErr.Missing = append(varErr.Missing, name)
+		}
+	}
+	if !allowUnused {
+		for name := range variables {
+			if !refs.required[name] && !refs.optional[name] {
+				varErr.Unused = append(varErr.Unused, name)
+			}
+		}
+	}
+	if len(varErr.Missing) == 0 && len(varErr.Unused) == 0 {
+		return nil
+	}
+	sort.Strings(varErr.Missing)
+	sort.Strings(varErr.Unused)
+	return varErr
+}
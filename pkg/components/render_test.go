@@ -0,0 +1,144 @@
+package components
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderVariables(t *testing.T) {
+	tests := []struct {
+		name        string
+		system      string
+		user        string
+		variables   map[string]interface{}
+		allowUnused bool
+		wantUser    string
+		missing     []string
+		unused      []string
+	}{
+		{
+			name:      "dot form",
+			user:      "Analyze {{.domain}}",
+			variables: map[string]interface{}{"domain": "example.com"},
+			wantUser:  "Analyze example.com",
+		},
+		{
+			name:      "legacy placeholders are upgraded",
+			user:      "Analyze {{domain}} and {{ tld }}",
+			variables: map[string]interface{}{"domain": "example", "tld": "com"},
+			wantUser:  "Analyze example and com",
+		},
+		{
+			name:      "template words are not upgraded",
+			user:      "{{if .flag}}on{{else}}off{{end}}",
+			variables: map[string]interface{}{"flag": true},
+			wantUser:  "on",
+		},
+		{
+			name:      "helpers",
+			user:      "{{.names | join \", \"}} {{.text | truncate 3}}",
+			variables: map[string]interface{}{"names": []string{"a", "b"}, "text": "abcdef"},
+			wantUser:  "a, b abc...",
+		},
+		{
+			name:      "variables used only in the system message count",
+			system:    "You review {{.kind}} reports.",
+			user:      "Go",
+			variables: map[string]interface{}{"kind": "DNS"},
+			wantUser:  "Go",
+		},
+		{
+			name:     "guarded variable may be absent",
+			user:     "Start{{if .previous}} after {{.previous}}{{end}}",
+			wantUser: "Start",
+		},
+		{
+			name:      "missing variables",
+			system:    "{{.role}}",
+			user:      "{{.domain}} {{.domain}}",
+			variables: map[string]interface{}{},
+			missing:   []string{"domain", "role"},
+		},
+		{
+			name:      "unused variables",
+			user:      "{{.domain}}",
+			variables: map[string]interface{}{"domain": "a", "extra": 1, "other": 2},
+			unused:    []string{"extra", "other"},
+		},
+		{
+			name:        "unused variables allowed",
+			user:        "{{.domain}}",
+			variables:   map[string]interface{}{"domain": "a", "extra": 1},
+			allowUnused: true,
+			wantUser:    "a",
+		},
+		{
+			name:      "missing and unused together",
+			user:      "{{.domain}}",
+			variables: map[string]interface{}{"host": "a"},
+			missing:   []string{"domain"},
+			unused:    []string{"host"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := Prompt{SystemMessage: tt.system, UserMessage: tt.user, Variables: tt.variables, AllowUnusedVariables: tt.allowUnused}
+			rendered, err := prompt.Render()
+			if tt.missing != nil || tt.unused != nil {
+				var varErr *VariableError
+				if !errors.As(err, &varErr) {
+					t.Fatalf("Render error = %v, want a *VariableError", err)
+				}
+				if !reflect.DeepEqual(varErr.Missing, tt.missing) || !reflect.DeepEqual(varErr.Unused, tt.unused) {
+					t.Errorf("missing %v, unused %v; want %v, %v", varErr.Missing, varErr.Unused, tt.missing, tt.unused)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rendered.UserMessage != tt.wantUser {
+				t.Errorf("user message = %q, want %q", rendered.UserMessage, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestRenderLiteralBraces(t *testing.T) {
+	prompt := Prompt{SystemMessage: `Reply like {{"a": 1}}.`, UserMessage: "Go"}
+	if _, err := prompt.Render(); err == nil || !strings.Contains(err.Error(), `{{"{{"}}`) {
+		t.Fatalf("Render error = %v, want a parse error explaining how to escape {{", err)
+	}
+
+	prompt.SystemMessage = `Reply like {{"{{"}}"a": 1}}.`
+	rendered, err := prompt.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rendered.SystemMessage, `Reply like {{"a": 1}}.`) {
+		t.Errorf("system message = %q", rendered.SystemMessage)
+	}
+
+	// A JSON example without {{ needs no escaping
+	prompt.SystemMessage = `Reply like {"a": {"b": 1}}.`
+	if rendered, err = prompt.Render(); err != nil || !strings.HasPrefix(rendered.SystemMessage, prompt.SystemMessage) {
+		t.Errorf("Render = %q, %v", rendered.SystemMessage, err)
+	}
+}
+
+func TestRenderLeavesPromptUnchanged(t *testing.T) {
+	prompt := Prompt{UserMessage: "Analyze {{domain}}", Variables: map[string]interface{}{"domain": "example.com"}}
+	rendered, err := prompt.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt.UserMessage != "Analyze {{domain}}" {
+		t.Errorf("Render changed the original prompt to %q", prompt.UserMessage)
+	}
+	again, err := rendered.Render()
+	if err != nil || again.UserMessage != rendered.UserMessage || again.SystemMessage != rendered.SystemMessage {
+		t.Errorf("rendering twice = %+v, %v", again, err)
+	}
+}
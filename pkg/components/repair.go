@@ -0,0 +1,415 @@
+package components
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Repairs reported by RepairJSON.
+const (
+	RepairCodeFence       = "stripped code fence"
+	RepairSurroundingText = "removed text around JSON"
+	RepairTrailingComma   = "removed trailing comma"
+	RepairSingleQuotes    = "converted single-quoted strings"
+	RepairSmartQuotes     = "replaced smart quotes"
+	RepairUnquotedKeys    = "quoted unquoted keys"
+	RepairBareValues      = "quoted bare values"
+	RepairLiterals        = "converted Python literals"
+	RepairComments        = "removed comments"
+	RepairUnterminated    = "closed unterminated string"
+	RepairTruncated       = "closed truncated brackets"
+	RepairMissingValue    = "filled missing value with null"
+)
+
+// codeFence matches a Markdown code fence around a whole response. The
+// closing fence must end the response, so backticks inside the body, even
+// a nested fence, are kept; a response cut off before it is fenced to the
+// end.
+var codeFence = regexp.MustCompile("(?s)^```([a-zA-Z0-9_-]*)[ \t]*\r?\n?(.*?)(?:\r?\n?```[ \t]*)?$")
+
+// unfence returns the body and language tag of a code fence wrapping all
+// of text, which should already be trimmed.
+func unfence(text string) (body, lang string, ok bool) {
+	m := codeFence.FindStringSubmatch(text)
+	if m == nil {
+		return "", "", false
+	}
+	return m[2], m[1], true
+}
+
+// maxRepairCandidates bounds how many opening brackets RepairJSON tries
+// when the text before the JSON contains brackets of its own.
+const maxRepairCandidates = 16
+
+// RepairJSON extracts the first JSON object or array from model output
+// and fixes the usual defects: Markdown code fences, surrounding prose,
+// comments, trailing commas, single or smart quotes, unquoted keys and
+// output cut off before its closing brackets. When a bracket in the prose,
+// such as "{see below}", does not repair into JSON, the next one is tried.
+// It returns the cleaned text and what was repaired; valid JSON comes back
+// unchanged with no repairs.
+func RepairJSON(input string) (string, []string) {
+	trimmed := strings.TrimSpace(input)
+	if json.Valid([]byte(trimmed)) {
+		return trimmed, nil
+	}
+
+	text, fenced := trimmed, false
+	if body, _, ok := unfence(trimmed); ok && strings.ContainsAny(body, "{[") {
+		text, fenced = body, true
+	}
+
+	var first *jsonRepairer
+	offset := 0
+	for tries := 0; tries < maxRepairCandidates; tries++ {
+		i := strings.IndexAny(text[offset:], "{[")
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		r := repairFrom(text, start, fenced)
+		if json.Valid(r.out) {
+			return string(r.out), r.repairs
+		}
+		if first == nil {
+			first = r
+		}
+		offset = start + 1
+	}
+	if first == nil {
+		r := &jsonRepairer{seen: map[string]bool{}}
+		if fenced {
+			r.note(RepairCodeFence)
+		}
+		return trimmed, r.repairs
+	}
+	// Nothing repaired cleanly; report the attempt from the first bracket
+	return string(first.out), first.repairs
+}
+
+// repairFrom repairs the value starting at text[start].
+func repairFrom(text string, start int, fenced bool) *jsonRepairer {
+	r := &jsonRepairer{seen: map[string]bool{}, lastAt: -1}
+	if fenced {
+		r.note(RepairCodeFence)
+	}
+	if strings.TrimSpace(text[:start]) != "" {
+		r.note(RepairSurroundingText)
+	}
+	r.src = []rune(text[start:])
+	r.run()
+	if r.pos < len(r.src) && strings.TrimSpace(string(r.src[r.pos:])) != "" {
+		r.note(RepairSurroundingText)
+	}
+	return r
+}
+
+// jsonRepairer rewrites a JSON-like value token by token. It tracks the
+// last non-space rune written so that each token is handled in constant
+// time however long the output grows.
+type jsonRepairer struct {
+	src     []rune
+	pos     int
+	out     []byte
+	last    rune   // last non-space rune written, 0 if none
+	lastAt  int    // offset of last in out, -1 if none
+	stack   []rune // expected closing brackets
+	repairs []string
+	seen    map[string]bool
+}
+
+func (r *jsonRepairer) note(repair string) {
+	if !r.seen[repair] {
+		r.seen[repair] = true
+		r.repairs = append(r.repairs, repair)
+	}
+}
+
+func (r *jsonRepairer) writeRune(c rune) {
+	if !unicode.IsSpace(c) {
+		r.last, r.lastAt = c, len(r.out)
+	}
+	r.out = utf8.AppendRune(r.out, c)
+}
+
+func (r *jsonRepairer) writeString(s string) {
+	for i, c := range s {
+		if !unicode.IsSpace(c) {
+			r.last, r.lastAt = c, len(r.out)+i
+		}
+	}
+	r.out = append(r.out, s...)
+}
+
+// truncate cuts the output to n bytes and finds the last non-space rune
+// again. It only walks back over the space before the cut, which is never
+// walked again once the next token is written.
+func (r *jsonRepairer) truncate(n int) {
+	r.out = r.out[:n]
+	for n > 0 {
+		c, size := utf8.DecodeLastRune(r.out[:n])
+		n -= size
+		if !unicode.IsSpace(c) {
+			r.last, r.lastAt = c, n
+			return
+		}
+	}
+	r.last, r.lastAt = 0, -1
+}
+
+// dropTrailingComma removes a comma written just before a closing bracket,
+// along with the space after it.
+func (r *jsonRepairer) dropTrailingComma() {
+	if r.last == ',' {
+		r.truncate(r.lastAt)
+		r.note(RepairTrailingComma)
+	}
+}
+
+// expectingKey reports whether the next token is an object key.
+func (r *jsonRepairer) expectingKey() bool {
+	if len(r.stack) == 0 || r.stack[len(r.stack)-1] != '}' {
+		return false
+	}
+	return r.last == '{' || r.last == ','
+}
+
+// run copies one top-level value, stopping when its brackets balance.
+func (r *jsonRepairer) run() {
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+		switch {
+		case c == '{' || c == '[':
+			r.writeRune(c)
+			if c == '{' {
+				r.stack = append(r.stack, '}')
+			} else {
+				r.stack = append(r.stack, ']')
+			}
+			r.pos++
+
+		case c == '}' || c == ']':
+			r.dropTrailingComma()
+			if len(r.stack) > 0 {
+				r.writeRune(r.stack[len(r.stack)-1])
+				r.stack = r.stack[:len(r.stack)-1]
+			}
+			r.pos++
+			if len(r.stack) == 0 {
+				return
+			}
+
+		case c == '"' || c == '\'' || c == '“' || c == '”' || c == '‘' || c == '’':
+			r.readString(c)
+
+		case c == '/' && r.pos+1 < len(r.src) && (r.src[r.pos+1] == '/' || r.src[r.pos+1] == '*'):
+			r.skipComment()
+
+		case c == '#':
+			// Python-style comment
+			r.skipComment()
+
+		case c == ',' || c == ':' || unicode.IsSpace(c):
+			r.writeRune(c)
+			r.pos++
+
+		case c == '-' || c == '+' || c == '.' || unicode.IsDigit(c):
+			r.readNumber()
+
+		default:
+			r.readWord()
+		}
+	}
+	r.finish()
+}
+
+// readString copies a string delimited by quote, converting it to a
+// double-quoted JSON string.
+func (r *jsonRepairer) readString(quote rune) {
+	closing := quote
+	switch quote {
+	case '\'':
+		r.note(RepairSingleQuotes)
+	case '“', '”':
+		closing = '”'
+		r.note(RepairSmartQuotes)
+	case '‘', '’':
+		closing = '’'
+		r.note(RepairSmartQuotes)
+	}
+	r.pos++
+
+	var b strings.Builder
+	b.WriteRune('"')
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+		switch {
+		case c == '\\' && r.pos+1 < len(r.src):
+			next := r.src[r.pos+1]
+			if next == '\'' {
+				// \' is not a JSON escape
+				b.WriteRune('\'')
+			} else {
+				b.WriteRune(c)
+				b.WriteRune(next)
+			}
+			r.pos += 2
+			continue
+		case c == closing:
+			b.WriteRune('"')
+			r.pos++
+			r.writeString(b.String())
+			return
+		case c == '"':
+			b.WriteString(`\"`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(c)
+		}
+		r.pos++
+	}
+
+	// Output ended inside the string
+	s := b.String()
+	if strings.HasSuffix(s, `\`) && !strings.HasSuffix(s, `\\`) {
+		s = s[:len(s)-1]
+	}
+	r.writeString(s + `"`)
+	r.note(RepairUnterminated)
+}
+
+func (r *jsonRepairer) skipComment() {
+	r.note(RepairComments)
+	if r.src[r.pos] == '/' && r.src[r.pos+1] == '*' {
+		for r.pos += 2; r.pos < len(r.src); r.pos++ {
+			if r.src[r.pos] == '*' && r.pos+1 < len(r.src) && r.src[r.pos+1] == '/' {
+				r.pos += 2
+				return
+			}
+		}
+		return
+	}
+	for r.pos < len(r.src) && r.src[r.pos] != '\n' {
+		r.pos++
+	}
+}
+
+func (r *jsonRepairer) readNumber() {
+	start := r.pos
+	for r.pos < len(r.src) && strings.ContainsRune("+-.0123456789eE", r.src[r.pos]) {
+		r.pos++
+	}
+	number := string(r.src[start:r.pos])
+	if strings.HasPrefix(number, "+") {
+		number = number[1:]
+	}
+	if strings.HasPrefix(number, ".") {
+		number = "0" + number
+	}
+	number = strings.TrimSuffix(number, ".")
+	if !json.Valid([]byte(number)) {
+		// Not a number after all, such as a version string
+		r.writeString(quoteJSON(number))
+		r.note(RepairBareValues)
+		return
+	}
+	r.writeString(number)
+}
+
+// readWord handles unquoted keys, literals and bare values.
+func (r *jsonRepairer) readWord() {
+	start := r.pos
+	key := r.expectingKey()
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+		if key {
+			if c == ':' || c == '}' || c == ',' || unicode.IsSpace(c) {
+				break
+			}
+		} else if strings.ContainsRune(",}]\n", c) {
+			break
+		}
+		r.pos++
+	}
+	word := strings.TrimRightFunc(string(r.src[start:r.pos]), unicode.IsSpace)
+	if word == "" {
+		// Stray character with no meaning in JSON
+		r.pos++
+		return
+	}
+
+	if key {
+		r.writeString(quoteJSON(word))
+		r.note(RepairUnquotedKeys)
+		return
+	}
+	switch word {
+	case "true", "false", "null":
+		r.writeString(word)
+	case "True", "False", "None":
+		r.writeString(map[string]string{"True": "true", "False": "false", "None": "null"}[word])
+		r.note(RepairLiterals)
+	default:
+		r.writeString(quoteJSON(word))
+		r.note(RepairBareValues)
+	}
+}
+
+// finish closes whatever the output left open.
+func (r *jsonRepairer) finish() {
+	if len(r.stack) == 0 {
+		return
+	}
+	if r.last == ':' {
+		r.writeString(" null")
+		r.note(RepairMissingValue)
+	}
+	// A key with no value is dropped along with its comma
+	r.dropDanglingKey()
+	r.dropTrailingComma()
+	for i := len(r.stack) - 1; i >= 0; i-- {
+		r.writeRune(r.stack[i])
+	}
+	r.stack = nil
+	r.note(RepairTruncated)
+}
+
+// dropDanglingKey removes an object key that was cut off before its colon.
+func (r *jsonRepairer) dropDanglingKey() {
+	if r.stack[len(r.stack)-1] != '}' {
+		return
+	}
+	if r.last != '"' {
+		return
+	}
+	s := r.out[:r.lastAt+1]
+	// Walk back over the final string and see what precedes it
+	i := len(s) - 2
+	for i >= 0 {
+		if s[i] == '"' && (i == 0 || s[i-1] != '\\') {
+			break
+		}
+		i--
+	}
+	if i < 0 {
+		return
+	}
+	before := bytes.TrimRightFunc(s[:i], unicode.IsSpace)
+	if bytes.HasSuffix(before, []byte("{")) || bytes.HasSuffix(before, []byte(",")) {
+		r.truncate(len(before))
+	}
+}
+
+func quoteJSON(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
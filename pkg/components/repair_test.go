@@ -0,0 +1,142 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		repairs []string
+	}{
+		{
+			name:  "valid",
+			input: `{"a": 1}`,
+			want:  `{"a": 1}`,
+		},
+		{
+			name:    "fence",
+			input:   "```json\n{\"a\": 1}\n```",
+			want:    `{"a": 1}`,
+			repairs: []string{RepairCodeFence},
+		},
+		{
+			name:    "backticks inside a fenced string",
+			input:   "```json\n{\"text\": \"use ```code``` here\", \"b\": 1}\n```",
+			want:    `{"text": "use ` + "```code```" + ` here", "b": 1}`,
+			repairs: []string{RepairCodeFence},
+		},
+		{
+			name:    "fence cut off before closing",
+			input:   "```json\n{\"a\": [1, 2",
+			want:    `{"a": [1, 2]}`,
+			repairs: []string{RepairCodeFence, RepairTruncated},
+		},
+		{
+			name:    "fence after prose",
+			input:   "Here it is:\n```json\n{\"a\": 1}\n```",
+			want:    `{"a": 1}`,
+			repairs: []string{RepairSurroundingText},
+		},
+		{
+			name:    "bracket in prose before the object",
+			input:   `Note {see below}: {"a":1}`,
+			want:    `{"a":1}`,
+			repairs: []string{RepairSurroundingText},
+		},
+		{
+			name:    "unbalanced bracket in prose",
+			input:   `Results (see [1) follow: [{"a": 1}]`,
+			want:    `[{"a": 1}]`,
+			repairs: []string{RepairSurroundingText},
+		},
+		{
+			name:    "trailing comma and comment",
+			input:   "{\"a\": 1, // one\n}",
+			want:    "{\"a\": 1\n}",
+			repairs: []string{RepairComments, RepairTrailingComma},
+		},
+		{
+			name:    "single quotes and unquoted keys",
+			input:   `{a: 'x'}`,
+			want:    `{"a": "x"}`,
+			repairs: []string{RepairUnquotedKeys, RepairSingleQuotes},
+		},
+		{
+			name:    "no JSON",
+			input:   "no brackets here",
+			want:    "no brackets here",
+			repairs: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, repairs := RepairJSON(tt.input)
+			if !sameJSON(got, tt.want) {
+				t.Errorf("RepairJSON(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if !reflect.DeepEqual(repairs, tt.repairs) {
+				t.Errorf("RepairJSON(%q) repairs = %q, want %q", tt.input, repairs, tt.repairs)
+			}
+		})
+	}
+}
+
+// sameJSON compares two documents by value when both are JSON and by text
+// otherwise.
+func sameJSON(a, b string) bool {
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return a == b
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// largeBrokenJSON returns about n bytes of output that needs every kind of
+// token-level repair and is cut off at the end.
+func largeBrokenJSON(n int) string {
+	var b strings.Builder
+	b.WriteString("Here you go:\n```json\n{items: [\n")
+	for i := 0; b.Len() < n; i++ {
+		fmt.Fprintf(&b, "  {name: 'item %d', tags: ['a', 'b',], // note\n   ok: True, score: .5,},\n", i)
+	}
+	return b.String()
+}
+
+func TestRepairJSONLargeInput(t *testing.T) {
+	// Repair is linear: this takes milliseconds, where a quadratic repair
+	// would take minutes
+	input := largeBrokenJSON(1 << 20)
+	done := make(chan string, 1)
+	go func() {
+		got, _ := RepairJSON(input)
+		done <- got
+	}()
+	select {
+	case got := <-done:
+		if !json.Valid([]byte(got)) {
+			t.Errorf("RepairJSON of %d bytes is not valid JSON", len(input))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("RepairJSON of %d bytes did not finish in 10s", len(input))
+	}
+}
+
+func BenchmarkRepairJSON(b *testing.B) {
+	for _, size := range []int{1 << 10, 1 << 14, 1 << 18} {
+		input := largeBrokenJSON(size)
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				RepairJSON(input)
+			}
+		})
+	}
+}
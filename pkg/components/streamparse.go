@@ -0,0 +1,371 @@
+package components
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of StreamEvent.
+const (
+	// StreamFieldSet reports an object field whose value is complete.
+	StreamFieldSet = "field_set"
+	// StreamItemAppended reports a complete array element.
+	StreamItemAppended = "item_appended"
+	// StreamStringDelta carries newly streamed characters of a string value.
+	StreamStringDelta = "string_delta"
+	// StreamReset means the previous output was rejected and a corrected
+	// response is starting, so partial state should be discarded.
+	StreamReset = "reset"
+)
+
+// StreamEvent is something StreamParser learned from the latest tokens.
+type StreamEvent struct {
+	Type string
+	// Path is a JSON Pointer to the value, such as /findings/0/title. The
+	// whole document is "".
+	Path  string
+	Value interface{} // the decoded value for field_set and item_appended
+	Delta string      // the new text for string_delta
+}
+
+// StreamParser consumes a JSON response as it streams in and reports
+// fields as they complete. Text before the first object or array, such as
+// a code fence, is skipped. It does not validate; Close parses the whole
+// response with JSONParser, repairs and schema checks included.
+type StreamParser struct {
+	parser *JSONParser
+
+	raw     strings.Builder // the response as received
+	started bool
+	done    bool
+	root    interface{} // the whole document once done
+
+	stack []*streamFrame
+
+	// Current string or literal
+	inString  bool
+	isKey     bool
+	escape    string
+	pending   []byte // decoded string bytes not yet sent as a delta
+	text      strings.Builder
+	literal   strings.Builder
+	inLiteral bool
+}
+
+type streamFrame struct {
+	object bool
+	key    string
+	count  int // completed array items
+	// expectKey is true in an object between '{' or ',' and the next key
+	expectKey bool
+	// hasKey is true between a key and the end of its value
+	hasKey bool
+	// The completed members so far
+	fields map[string]interface{}
+	items  []interface{}
+}
+
+// value returns the frame's container as built so far.
+func (f *streamFrame) value() interface{} {
+	if f.object {
+		return f.fields
+	}
+	return f.items
+}
+
+// NewStreamParser creates a parser whose Close validates against fields.
+func NewStreamParser(fields []SchemaField) *StreamParser {
+	return &StreamParser{parser: NewJSONParser(fields)}
+}
+
+// Write feeds the next chunk of the response and returns the events it
+// completed.
+func (p *StreamParser) Write(chunk string) []StreamEvent {
+	p.raw.WriteString(chunk)
+	var events []StreamEvent
+	for i := 0; i < len(chunk) && !p.done; i++ {
+		events = p.scan(chunk[i], events)
+	}
+	if p.inString && !p.isKey {
+		events = p.flushDelta(events)
+	}
+	return events
+}
+
+// Partial returns a best-effort decoding of the response so far, with
+// open strings, arrays and objects closed and a key still waiting for its
+// value set to null. An escape sequence or character split across chunks
+// is left out until it is complete, and a number or literal only appears
+// once it is valid. It is nil before the first bracket arrives.
+//
+// Partial is built from the values completed so far, so calling it after
+// every chunk costs the size of the open containers, not of the whole
+// response. Completed values are shared with earlier results and events
+// and must not be modified.
+func (p *StreamParser) Partial() interface{} {
+	if !p.started {
+		return nil
+	}
+	if p.done {
+		return p.root
+	}
+
+	var current interface{}
+	if p.inString && !p.isKey {
+		current = validUTF8Prefix(p.text.String())
+	} else if p.inLiteral {
+		var value interface{}
+		if err := json.Unmarshal([]byte(p.literal.String()), &value); err == nil {
+			current = value
+		}
+	}
+	hasCurrent := current != nil || (p.inLiteral && p.literal.String() == "null")
+
+	// Copy the open containers from the innermost out, placing each inside
+	// its parent
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		frame := p.stack[i]
+		if frame.object {
+			fields := make(map[string]interface{}, len(frame.fields)+1)
+			for k, v := range frame.fields {
+				fields[k] = v
+			}
+			if frame.hasKey {
+				// A key without its value yet is null
+				fields[frame.key] = current
+			}
+			current = fields
+		} else {
+			items := make([]interface{}, len(frame.items), len(frame.items)+1)
+			copy(items, frame.items)
+			if hasCurrent {
+				items = append(items, current)
+			}
+			current = items
+		}
+		hasCurrent = true
+	}
+	return current
+}
+
+// validUTF8Prefix drops a multi-byte character cut off at the end of s.
+func validUTF8Prefix(s string) string {
+	for i := 1; i <= 3 && i <= len(s); i++ {
+		if utf8.RuneStart(s[len(s)-i]) {
+			if !utf8.FullRuneInString(s[len(s)-i:]) {
+				return s[:len(s)-i]
+			}
+			break
+		}
+	}
+	return s
+}
+
+// Text returns the response received so far.
+func (p *StreamParser) Text() string {
+	return p.raw.String()
+}
+
+// Close parses and validates the complete response.
+func (p *StreamParser) Close() (interface{}, error) {
+	return p.parser.Parse(p.raw.String())
+}
+
+func (p *StreamParser) scan(c byte, events []StreamEvent) []StreamEvent {
+	if !p.started {
+		if c != '{' && c != '[' {
+			return events
+		}
+		p.started = true
+	}
+
+	if p.inString {
+		return p.scanString(c, events)
+	}
+
+	if p.inLiteral {
+		if strings.IndexByte(",]} \t\r\n", c) < 0 {
+			p.literal.WriteByte(c)
+			return events
+		}
+		events = p.endLiteral(events)
+	}
+
+	switch c {
+	case '{', '[':
+		frame := &streamFrame{object: c == '{', expectKey: c == '{'}
+		if frame.object {
+			frame.fields = map[string]interface{}{}
+		} else {
+			frame.items = []interface{}{}
+		}
+		p.stack = append(p.stack, frame)
+	case '}', ']':
+		if len(p.stack) == 0 {
+			return events
+		}
+		frame := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		events = p.complete(frame.value(), events)
+		if len(p.stack) == 0 {
+			p.done = true
+		}
+	case '"':
+		p.inString = true
+		p.isKey = p.top() != nil && p.top().object && p.top().expectKey
+		p.text.Reset()
+		p.pending = p.pending[:0]
+	case ',':
+		if top := p.top(); top != nil && top.object {
+			top.expectKey = true
+		}
+	case ':', ' ', '\t', '\r', '\n':
+	default:
+		p.inLiteral = true
+		p.literal.Reset()
+		p.literal.WriteByte(c)
+	}
+	return events
+}
+
+func (p *StreamParser) scanString(c byte, events []StreamEvent) []StreamEvent {
+	if p.escape != "" {
+		p.escape += string(c)
+		decoded, ok := decodeEscape(p.escape)
+		if ok {
+			p.escape = ""
+			p.text.WriteString(decoded)
+			if !p.isKey {
+				p.pending = append(p.pending, decoded...)
+			}
+		}
+		return events
+	}
+
+	switch c {
+	case '\\':
+		p.escape = `\`
+	case '"':
+		p.inString = false
+		if p.isKey {
+			top := p.top()
+			top.key = p.text.String()
+			top.expectKey = false
+			top.hasKey = true
+			return events
+		}
+		events = p.flushDelta(events)
+		return p.complete(p.text.String(), events)
+	default:
+		p.text.WriteByte(c)
+		if !p.isKey {
+			p.pending = append(p.pending, c)
+		}
+	}
+	return events
+}
+
+// decodeEscape decodes a complete escape sequence, reporting false while
+// more bytes are needed. A high surrogate waits for its low half.
+func decodeEscape(seq string) (string, bool) {
+	if len(seq) < 2 {
+		return "", false
+	}
+	if seq[1] != 'u' {
+		s, err := strconv.Unquote(`"` + seq + `"`)
+		if err != nil {
+			// Unknown escape; keep the character
+			return seq[1:], true
+		}
+		return s, true
+	}
+	if len(seq) < 6 {
+		return "", false
+	}
+	if r, err := strconv.ParseUint(seq[2:6], 16, 32); err == nil && r >= 0xD800 && r < 0xDC00 && len(seq) < 12 {
+		if len(seq) == 6 || seq[6] == '\\' && (len(seq) == 7 || seq[7] == 'u') {
+			return "", false
+		}
+	}
+	var s string
+	if err := json.Unmarshal([]byte(`"`+seq+`"`), &s); err != nil {
+		return string(utf8.RuneError), true
+	}
+	return s, true
+}
+
+func (p *StreamParser) endLiteral(events []StreamEvent) []StreamEvent {
+	p.inLiteral = false
+	var value interface{}
+	if err := json.Unmarshal([]byte(p.literal.String()), &value); err != nil {
+		// Not valid JSON; report the text and let Close sort it out
+		value = p.literal.String()
+	}
+	return p.complete(value, events)
+}
+
+// complete reports a finished value at the current position.
+func (p *StreamParser) complete(value interface{}, events []StreamEvent) []StreamEvent {
+	top := p.top()
+	if top == nil {
+		p.root = value
+		return append(events, StreamEvent{Type: StreamFieldSet, Path: "", Value: value})
+	}
+	event := StreamEvent{Path: p.path(), Value: value}
+	if top.object {
+		event.Type = StreamFieldSet
+		if top.hasKey {
+			top.fields[top.key] = value
+			top.hasKey = false
+		}
+	} else {
+		event.Type = StreamItemAppended
+		top.items = append(top.items, value)
+		top.count++
+	}
+	return append(events, event)
+}
+
+// flushDelta sends the complete UTF-8 characters of the current string
+// received since the last delta.
+func (p *StreamParser) flushDelta(events []StreamEvent) []StreamEvent {
+	n := len(p.pending)
+	// Hold back a multi-byte character split across chunks
+	for i := 1; i <= 3 && i <= len(p.pending); i++ {
+		if utf8.RuneStart(p.pending[len(p.pending)-i]) {
+			if !utf8.FullRune(p.pending[len(p.pending)-i:]) {
+				n = len(p.pending) - i
+			}
+			break
+		}
+	}
+	if n == 0 {
+		return events
+	}
+	events = append(events, StreamEvent{Type: StreamStringDelta, Path: p.path(), Delta: string(p.pending[:n])})
+	p.pending = append(p.pending[:0], p.pending[n:]...)
+	return events
+}
+
+func (p *StreamParser) top() *streamFrame {
+	if len(p.stack) == 0 {
+		return nil
+	}
+	return p.stack[len(p.stack)-1]
+}
+
+// path is the JSON Pointer of the value being read.
+func (p *StreamParser) path() string {
+	var b strings.Builder
+	for _, frame := range p.stack {
+		b.WriteByte('/')
+		if frame.object {
+			b.WriteString(escapePointer(frame.key))
+		} else {
+			b.WriteString(strconv.Itoa(frame.count))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package components
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const streamDoc = "```json\n" + `{"title": "café 😀 日本", "n": -12.5, "ok": true, "none": null, "tags": ["a\"b", "c\\d"], "nested": {"items": [{"x": 1}, {"x": 2}], "s": "tab\there"}}` + "\n```"
+
+// streamDocJSON is streamDoc without its fence.
+var streamDocJSON = strings.TrimSuffix(strings.TrimPrefix(streamDoc, "```json\n"), "\n```")
+
+// collect feeds chunks to a new parser and returns its events with string
+// deltas joined per path, and the Partial after every chunk.
+func collect(t *testing.T, chunks []string) (map[string]string, []StreamEvent, []interface{}) {
+	t.Helper()
+	parser := NewStreamParser(nil)
+	deltas := map[string]string{}
+	var completed []StreamEvent
+	var partials []interface{}
+	for _, chunk := range chunks {
+		for _, event := range parser.Write(chunk) {
+			if event.Type == StreamStringDelta {
+				deltas[event.Path] += event.Delta
+			} else {
+				completed = append(completed, event)
+			}
+		}
+		partials = append(partials, parser.Partial())
+	}
+	return deltas, completed, partials
+}
+
+func TestStreamParserChunkSplits(t *testing.T) {
+	var want interface{}
+	if err := json.Unmarshal([]byte(streamDocJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	wantDeltas, wantEvents, _ := collect(t, []string{streamDoc})
+	if got := wantDeltas["/title"]; got != "café 😀 日本" {
+		t.Fatalf("title delta = %q", got)
+	}
+
+	// Every split point, including inside escapes and multi-byte runes
+	for i := 1; i < len(streamDoc); i++ {
+		deltas, events, partials := collect(t, []string{streamDoc[:i], streamDoc[i:]})
+		if !reflect.DeepEqual(deltas, wantDeltas) {
+			t.Errorf("split at %d: deltas = %q, want %q", i, deltas, wantDeltas)
+		}
+		if !reflect.DeepEqual(events, wantEvents) {
+			t.Errorf("split at %d: events differ", i)
+		}
+		if !reflect.DeepEqual(partials[1], want) {
+			t.Errorf("split at %d: final Partial = %v", i, partials[1])
+		}
+	}
+}
+
+func TestStreamParserPartialByteByByte(t *testing.T) {
+	var chunks []string
+	for i := 0; i < len(streamDoc); i++ {
+		chunks = append(chunks, streamDoc[i:i+1])
+	}
+	_, _, partials := collect(t, chunks)
+
+	start := strings.Index(streamDoc, "{")
+	for i, partial := range partials {
+		if i < start {
+			if partial != nil {
+				t.Errorf("byte %d: Partial = %v before the document starts", i, partial)
+			}
+			continue
+		}
+		object, ok := partial.(map[string]interface{})
+		if !ok {
+			t.Fatalf("byte %d (%q): Partial = %#v, want an object", i, streamDoc[:i+1], partial)
+		}
+		if title, ok := object["title"].(string); ok && !strings.HasPrefix("café 😀 日本", title) {
+			t.Errorf("byte %d: partial title %q is not a prefix of the title", i, title)
+		}
+	}
+}
+
+func TestStreamParserPartial(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"a": "x\u00`, `{"a": "x"}`},
+		{`{"a": "x\ud83d\u`, `{"a": "x"}`},
+		{`{"a": "x\`, `{"a": "x"}`},
+		{`{"a": tr`, `{"a": null}`},
+		{`{"a": 12`, `{"a": 12}`},
+		{`{"a": 1, "b`, `{"a": 1}`},
+		{`{"a": [1, {"b": [`, `{"a": [1, {"b": []}]}`},
+		{`[{"a": 1}, "s`, `[{"a": 1}, "s"]`},
+	}
+	for _, tt := range tests {
+		parser := NewStreamParser(nil)
+		parser.Write(tt.input)
+		var want interface{}
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		if got := parser.Partial(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Partial after %q = %#v, want %s", tt.input, got, tt.want)
+		}
+	}
+}
@@ -1,18 +1,75 @@
 package components
 
-import "fmt"
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+)
 
 type ToolType interface {
-    Run() (interface{}, error)
+    Run(ctx context.Context) (interface{}, error)
 }
 
-type HandlerFunc func(inputs interface{}) (interface{}, error)
+// HandlerFunc runs a tool. It should stop and return when ctx is done;
+// subprocesses belong under exec.CommandContext.
+type HandlerFunc func(ctx context.Context, inputs interface{}) (interface{}, error)
+
+// DefaultToolTimeout bounds a tool run when Tool.Timeout is zero.
+const DefaultToolTimeout = 30 * time.Second
+
+// ErrToolTimeout matches, with errors.Is, a tool run that hit its deadline.
+var ErrToolTimeout = errors.New("tool timed out")
+
+// ToolTimeoutError is returned by Tool.Run when the tool did not finish
+// before its timeout or the caller's deadline. It matches both
+// ErrToolTimeout and context.DeadlineExceeded.
+type ToolTimeoutError struct {
+    Tool    string
+    // Timeout is how long the tool was given: its own Timeout or, when
+    // Caller is set, the time that was left before the caller's deadline.
+    Timeout time.Duration
+    Caller  bool
+}
+
+func (e *ToolTimeoutError) Error() string {
+    switch {
+    case e.Caller && e.Timeout > 0:
+        return fmt.Sprintf("tool %s timed out after %s at the caller's deadline", e.Tool, e.Timeout)
+    case e.Caller:
+        return fmt.Sprintf("tool %s timed out at the caller's deadline", e.Tool)
+    case e.Timeout > 0:
+        return fmt.Sprintf("tool %s timed out after %s", e.Tool, e.Timeout)
+    }
+    return fmt.Sprintf("tool %s timed out", e.Tool)
+}
+
+func (e *ToolTimeoutError) Unwrap() []error {
+    return []error{ErrToolTimeout, context.DeadlineExceeded}
+}
 
 type Tool struct {
     Name         string      `json:"name"`
     Description  string      `json:"description"`
+    // Inputs holds the arguments for the next Run. Tools made by NewTool
+    // accept their input struct, a JSON string or a decoded map.
     Inputs       interface{} `json:"inputs"`
     HandlerFunc  HandlerFunc `json:"-"`
+    // Timeout bounds each run; zero means DefaultToolTimeout and a negative
+    // value only the caller's context.
+    Timeout      time.Duration `json:"-"`
+    // Schema is the JSON Schema of the tool's inputs, sent to providers
+    // when the prompt uses native tool calling.
+    Schema       map[string]interface{} `json:"-"`
+}
+
+// ToolCall is a native tool invocation requested by the model.
+type ToolCall struct {
+    ID        string
+    Name      string
+    Arguments string // raw JSON arguments
 }
 
 type ToolSelectionOutput struct {
@@ -24,10 +81,128 @@ type ToolList struct {
     Tools map[string]Tool  // Changed from 'tools' to 'Tools' for consistency
 }
 
-func (t Tool) Run() (interface{}, error) {
-    output, err := t.HandlerFunc(t.Inputs)
-    if err != nil {
-        return nil, fmt.Errorf("error running tool: %v", err)
+// DefaultToolWorkers is how many calls RunCalls runs at once when not told.
+const DefaultToolWorkers = 4
+
+// ToolResult is the outcome of one call made by RunCalls. Exactly one of
+// Output and Error is set.
+type ToolResult struct {
+    ID     string      `json:"id"`
+    Name   string      `json:"tool_name"`
+    Output interface{} `json:"tool_output,omitempty"`
+    Error  string      `json:"tool_error,omitempty"`
+    // Err is the error behind Error, for errors.Is and errors.As.
+    Err    error       `json:"-"`
+}
+
+// RunCalls runs calls concurrently, at most workers at a time, and returns
+// one result per call in the same order. A call naming an unknown tool, with
+// arguments that are not JSON, or whose tool fails or times out gets an
+// error in its own result; the other calls are unaffected. Arguments are
+// decoded before they become the tool's Inputs.
+func (l *ToolList) RunCalls(ctx context.Context, calls []ToolCall, workers int) []ToolResult {
+    if workers <= 0 {
+        workers = DefaultToolWorkers
+    }
+    results := make([]ToolResult, len(calls))
+    indexes := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < min(workers, len(calls)); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range indexes {
+                results[i] = l.runCall(ctx, calls[i])
+            }
+        }()
+    }
+    for i := range calls {
+        indexes <- i
+    }
+    close(indexes)
+    wg.Wait()
+    return results
+}
+
+func (l *ToolList) runCall(ctx context.Context, call ToolCall) ToolResult {
+    result := ToolResult{ID: call.ID, Name: call.Name}
+    tool, exists := l.Tools[call.Name]
+    if !exists {
+        result.Err = fmt.Errorf("tool %s not found", call.Name)
+    } else {
+        var inputs interface{}
+        if call.Arguments != "" {
+            if err := json.Unmarshal([]byte(call.Arguments), &inputs); err != nil {
+                result.Err = &ToolInputError{Tool: call.Name, Err: fmt.Errorf("arguments are not valid JSON: %v", err)}
+            }
+        }
+        if result.Err == nil {
+            // tool is a copy, so concurrent calls to one tool do not share Inputs
+            tool.Inputs = inputs
+            result.Output, result.Err = tool.Run(ctx)
+        }
+    }
+    if result.Err != nil {
+        result.Error = result.Err.Error()
+    }
+    return result
+}
+
+// Run calls the handler with Inputs under the tool's timeout. A deadline
+// is reported as a *ToolTimeoutError and a cancellation as ctx's error. A
+// handler that ignores ctx is abandoned when it expires, not stopped, and
+// one that panics fails the run with the panic as its error.
+func (t Tool) Run(ctx context.Context) (interface{}, error) {
+    start, parent := time.Now(), ctx
+    timeout := t.Timeout
+    if timeout == 0 {
+        timeout = DefaultToolTimeout
+    }
+    if timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, timeout)
+        defer cancel()
+    } else {
+        timeout = 0
+    }
+
+    type outcome struct {
+        output interface{}
+        err    error
+    }
+    done := make(chan outcome, 1)
+    go func() {
+        // The handler runs apart from the caller, who could not recover
+        // a panic here
+        defer func() {
+            if r := recover(); r != nil {
+                done <- outcome{err: fmt.Errorf("tool %s panicked: %v", t.Name, r)}
+            }
+        }()
+        output, err := t.HandlerFunc(ctx, t.Inputs)
+        done <- outcome{output, err}
+    }()
+
+    select {
+    case result := <-done:
+        if result.err == nil {
+            return result.output, nil
+        }
+        // A handler killed by the deadline, e.g. exec.CommandContext,
+        // fails with its own error
+        if ctx.Err() == nil {
+            return nil, fmt.Errorf("error running tool: %w", result.err)
+        }
+    case <-ctx.Done():
+    }
+
+    if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+        if deadline, ok := parent.Deadline(); ok && parent.Err() != nil {
+            // The caller's deadline came before the tool's own
+            left := max(deadline.Sub(start).Round(time.Millisecond), 0)
+            return nil, &ToolTimeoutError{Tool: t.Name, Timeout: left, Caller: true}
+        }
+        return nil, &ToolTimeoutError{Tool: t.Name, Timeout: timeout}
     }
-    return output, nil
+    return nil, fmt.Errorf("tool %s canceled: %w", t.Name, ctx.Err())
 }
\ No newline at end of file
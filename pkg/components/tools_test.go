@@ -0,0 +1,148 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestToolRunTimeout(t *testing.T) {
+	tool := Tool{Name: "hang", Timeout: 20 * time.Millisecond, HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, errors.New("killed")
+	}}
+	_, err := tool.Run(context.Background())
+	var timeout *ToolTimeoutError
+	if !errors.As(err, &timeout) || !errors.Is(err, ErrToolTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run error = %v, want a *ToolTimeoutError", err)
+	}
+	if timeout.Tool != "hang" || timeout.Timeout != 20*time.Millisecond {
+		t.Errorf("timeout = %+v", timeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = tool.Run(ctx)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrToolTimeout) {
+		t.Errorf("Run with cancelled context = %v, want context.Canceled only", err)
+	}
+}
+
+func TestRunCalls(t *testing.T) {
+	var running, peak int32
+	echo := Tool{Name: "echo", HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		args := inputs.(map[string]interface{})
+		if args["fail"] == true {
+			return nil, errors.New("boom")
+		}
+		return args["value"], nil
+	}}
+	hang := Tool{Name: "hang", Timeout: 20 * time.Millisecond, HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	list := &ToolList{Tools: map[string]Tool{"echo": echo, "hang": hang}}
+
+	calls := []ToolCall{
+		{ID: "a", Name: "echo", Arguments: `{"value": "first"}`},
+		{ID: "b", Name: "hang", Arguments: `{}`},
+		{ID: "c", Name: "missing", Arguments: `{}`},
+		{ID: "d", Name: "echo", Arguments: `{"fail": true}`},
+		{ID: "e", Name: "echo", Arguments: `not json`},
+	}
+	for i := 0; i < 5; i++ {
+		calls = append(calls, ToolCall{ID: fmt.Sprintf("x%d", i), Name: "echo", Arguments: fmt.Sprintf(`{"value": "%d"}`, i)})
+	}
+
+	results := list.RunCalls(context.Background(), calls, 2)
+	if len(results) != len(calls) {
+		t.Fatalf("got %d results, want %d", len(results), len(calls))
+	}
+	for i, r := range results {
+		if r.ID != calls[i].ID || r.Name != calls[i].Name {
+			t.Errorf("result %d is %s/%s, want %s/%s", i, r.ID, r.Name, calls[i].ID, calls[i].Name)
+		}
+		if (r.Err == nil) != (r.Error == "") {
+			t.Errorf("result %s: Err %v disagrees with Error %q", r.ID, r.Err, r.Error)
+		}
+	}
+	if results[0].Output != "first" || results[0].Err != nil {
+		t.Errorf("call a = %+v", results[0])
+	}
+	if !errors.Is(results[1].Err, ErrToolTimeout) {
+		t.Errorf("call b error = %v, want a timeout", results[1].Err)
+	}
+	if results[2].Err == nil {
+		t.Error("call c to an unknown tool succeeded")
+	}
+	if results[3].Err == nil {
+		t.Error("failing call d succeeded")
+	}
+	var inputErr *ToolInputError
+	if !errors.As(results[4].Err, &inputErr) {
+		t.Errorf("call e error = %v, want a *ToolInputError", results[4].Err)
+	}
+	for i := 0; i < 5; i++ {
+		if got := results[5+i].Output; got != fmt.Sprint(i) {
+			t.Errorf("call x%d output = %v", i, got)
+		}
+	}
+	if peak > 2 {
+		t.Errorf("%d calls ran at once, want at most 2", peak)
+	}
+}
+
+func TestToolRunCallerDeadline(t *testing.T) {
+	tool := Tool{Name: "hang", Timeout: time.Minute, HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := tool.Run(ctx)
+	var timeout *ToolTimeoutError
+	if !errors.As(err, &timeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run error = %v, want a *ToolTimeoutError", err)
+	}
+	if !timeout.Caller || timeout.Timeout > 20*time.Millisecond {
+		t.Errorf("timeout = %+v, want the caller's 20ms deadline", timeout)
+	}
+	if strings.Contains(err.Error(), "1m0s") {
+		t.Errorf("error %q reports the tool's own timeout", err)
+	}
+}
+
+func TestRunCallsRecoversPanics(t *testing.T) {
+	list := &ToolList{Tools: map[string]Tool{
+		"panic": {Name: "panic", HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+			panic("bad input")
+		}},
+		"echo": {Name: "echo", HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+			return "ok", nil
+		}},
+	}}
+	results := list.RunCalls(context.Background(), []ToolCall{
+		{ID: "a", Name: "panic"},
+		{ID: "b", Name: "echo"},
+	}, 2)
+	if results[0].Err == nil || !strings.Contains(results[0].Error, "panicked: bad input") {
+		t.Errorf("panicking call = %+v, want its panic as the error", results[0])
+	}
+	if results[1].Output != "ok" || results[1].Err != nil {
+		t.Errorf("other call = %+v, want it unaffected", results[1])
+	}
+}
@@ -0,0 +1,302 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaFor derives schema fields from the exported fields of struct type T.
+// Field names come from json tags, and fields with a json "-" tag are
+// skipped. A field is required unless its json tag has omitempty or it is
+// a pointer; a required:"true" or required:"false" tag overrides that.
+// These tags add constraints:
+//
+//	description:"..."        field description
+//	enum:"low,medium,high"   allowed values, comma separated
+//	format:"uri"             string format
+//	pattern:"^[a-z]+$"       string pattern
+//	minimum:"0" maximum:"1"  numeric bounds
+//	minLength:"1" maxLength:"80"
+//	minItems:"1" maxItems:"10"
+//
+// Nested structs become objects, slices become arrays, maps become objects
+// without fixed properties and time.Time becomes a date-time string.
+func SchemaFor[T any]() ([]SchemaField, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema for %s: want a struct type", t)
+	}
+
+	if cached, ok := schemaCache.Load(t); ok {
+		return copyFields(cached.([]SchemaField)), nil
+	}
+	fields, err := structFields(t, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, fmt.Errorf("schema for %s: %w", t, err)
+	}
+	schemaCache.Store(t, fields)
+	return copyFields(fields), nil
+}
+
+// copyFields deep-copies fields, so callers may change what SchemaFor
+// returns without affecting the cached schema.
+func copyFields(fields []SchemaField) []SchemaField {
+	if fields == nil {
+		return nil
+	}
+	out := make([]SchemaField, len(fields))
+	for i, f := range fields {
+		out[i] = f.clone()
+	}
+	return out
+}
+
+// clone returns a deep copy of f.
+func (f SchemaField) clone() SchemaField {
+	f.Properties = copyFields(f.Properties)
+	if f.Items != nil {
+		items := f.Items.clone()
+		f.Items = &items
+	}
+	if f.Enum != nil {
+		f.Enum = append([]interface{}(nil), f.Enum...)
+	}
+	f.AdditionalProperties = copyPtr(f.AdditionalProperties)
+	f.Minimum = copyPtr(f.Minimum)
+	f.Maximum = copyPtr(f.Maximum)
+	f.ExclusiveMinimum = copyPtr(f.ExclusiveMinimum)
+	f.ExclusiveMaximum = copyPtr(f.ExclusiveMaximum)
+	f.MinLength = copyPtr(f.MinLength)
+	f.MaxLength = copyPtr(f.MaxLength)
+	f.MinItems = copyPtr(f.MinItems)
+	f.MaxItems = copyPtr(f.MaxItems)
+	return f
+}
+
+func copyPtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// ParseAs parses model output against the schema derived from T and
+// decodes it into a T.
+func ParseAs[T any](input string) (T, error) {
+	var zero T
+	fields, err := SchemaFor[T]()
+	if err != nil {
+		return zero, err
+	}
+	result, err := NewJSONParser(fields).Parse(input)
+	if err != nil {
+		return zero, err
+	}
+	return Decode[T](result)
+}
+
+// Decode converts a parsed result, such as the map returned by a
+// workflow, into a T.
+func Decode[T any](value interface{}) (T, error) {
+	var result T
+	data, err := json.Marshal(value)
+	if err != nil {
+		return result, fmt.Errorf("encoding result: %w", err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("decoding result into %T: %w", result, err)
+	}
+	return result, nil
+}
+
+var schemaCache sync.Map // reflect.Type -> []SchemaField
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func structFields(t reflect.Type, visiting map[reflect.Type]bool) ([]SchemaField, error) {
+	if visiting[t] {
+		return nil, fmt.Errorf("recursive type %s", t)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var fields []SchemaField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// Untagged embedded structs are flattened, as encoding/json does
+		if sf.Anonymous && name == "" {
+			et := sf.Type
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				embedded, err := structFields(et, visiting)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, embedded...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		field, err := typeField(sf.Type, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		field.Field = name
+		field.Required = sf.Type.Kind() != reflect.Pointer && !strings.Contains(opts, "omitempty")
+		if err := applyTags(&field, sf.Tag); err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// typeField describes a value of type t.
+func typeField(t reflect.Type, visiting map[reflect.Type]bool) (SchemaField, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return SchemaField{Type: "string", Format: "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return SchemaField{Type: "string"}, nil
+	case reflect.Bool:
+		return SchemaField{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return SchemaField{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return SchemaField{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices as base64 strings
+			return SchemaField{Type: "string"}, nil
+		}
+		items, err := typeField(t.Elem(), visiting)
+		if err != nil {
+			return SchemaField{}, err
+		}
+		return SchemaField{Type: "array", Items: &items}, nil
+	case reflect.Map:
+		return SchemaField{Type: "object"}, nil
+	case reflect.Struct:
+		properties, err := structFields(t, visiting)
+		if err != nil {
+			return SchemaField{}, err
+		}
+		return SchemaField{Type: "object", Properties: properties}, nil
+	case reflect.Interface:
+		// Any JSON value
+		return SchemaField{}, nil
+	}
+	return SchemaField{}, fmt.Errorf("unsupported type %s", t)
+}
+
+func applyTags(field *SchemaField, tag reflect.StructTag) error {
+	if v, ok := tag.Lookup("required"); ok {
+		required, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("required tag: %w", err)
+		}
+		field.Required = required
+	}
+	field.Description = tag.Get("description")
+
+	// Constraints on a slice field describe its elements when the slice
+	// itself cannot use them
+	target := field
+	if field.Type == "array" && field.Items != nil {
+		target = field.Items
+	}
+	if v, ok := tag.Lookup("format"); ok {
+		target.Format = v
+	}
+	if v, ok := tag.Lookup("pattern"); ok {
+		target.Pattern = v
+	}
+
+	if v := tag.Get("enum"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			value, err := enumValue(target.Type, strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("enum tag: %w", err)
+			}
+			target.Enum = append(target.Enum, value)
+		}
+	}
+
+	floats := []struct {
+		key  string
+		dest **float64
+	}{
+		{"minimum", &target.Minimum},
+		{"maximum", &target.Maximum},
+	}
+	for _, f := range floats {
+		if v, ok := tag.Lookup(f.key); ok {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("%s tag: %w", f.key, err)
+			}
+			*f.dest = &n
+		}
+	}
+
+	ints := []struct {
+		key  string
+		dest **int64
+	}{
+		{"minLength", &target.MinLength},
+		{"maxLength", &target.MaxLength},
+		{"minItems", &field.MinItems},
+		{"maxItems", &field.MaxItems},
+	}
+	for _, f := range ints {
+		if v, ok := tag.Lookup(f.key); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s tag: %w", f.key, err)
+			}
+			*f.dest = &n
+		}
+	}
+	return nil
+}
+
+func enumValue(typ, s string) (interface{}, error) {
+	switch typ {
+	case "integer":
+		return strconv.ParseInt(s, 10, 64)
+	case "number":
+		return strconv.ParseFloat(s, 64)
+	case "boolean":
+		return strconv.ParseBool(s)
+	}
+	return s, nil
+}
@@ -0,0 +1,39 @@
+package components
+
+import (
+	"reflect"
+	"testing"
+)
+
+type schemaCacheItem struct {
+	Title string `json:"title" maxLength:"80"`
+}
+
+type schemaCacheReport struct {
+	Risk  string            `json:"risk" enum:"low,high"`
+	Items []schemaCacheItem `json:"items"`
+	Owner schemaCacheItem   `json:"owner"`
+}
+
+func TestSchemaForReturnsIndependentCopies(t *testing.T) {
+	first, err := SchemaFor[schemaCacheReport]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := SchemaFor[schemaCacheReport]()
+
+	// Change every nested part of the first result
+	first[0].Enum[0] = "changed"
+	first[1].Items.Properties[0].Field = "changed"
+	*first[1].Items.Properties[0].MaxLength = 1
+	first[2].Properties[0].Description = "changed"
+	first[2].Properties = append(first[2].Properties, SchemaField{Field: "extra"})
+
+	again, err := SchemaFor[schemaCacheReport]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("cached schema changed:\ngot  %+v\nwant %+v", again, want)
+	}
+}
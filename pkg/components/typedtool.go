@@ -0,0 +1,115 @@
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ToolInputError reports tool arguments that could not be decoded into
+// the tool's input type or did not match its schema.
+type ToolInputError struct {
+	Tool string
+	Err  error
+}
+
+func (e *ToolInputError) Error() string {
+	var verr *ValidationError
+	if errors.As(e.Err, &verr) {
+		// ValidationError words itself for model output
+		parts := make([]string, len(verr.Violations))
+		for i, v := range verr.Violations {
+			parts[i] = v.String()
+		}
+		return fmt.Sprintf("invalid input for tool %s: arguments do not match the tool's input schema: %s", e.Tool, strings.Join(parts, "; "))
+	}
+	return fmt.Sprintf("invalid input for tool %s: %v", e.Tool, e.Err)
+}
+
+func (e *ToolInputError) Unwrap() error {
+	return e.Err
+}
+
+// NewTool creates a tool from a handler over typed input and output
+// structs. The input's JSON Schema is derived with SchemaFor, so the same
+// struct tags apply, and becomes Tool.Schema. Whatever is in Tool.Inputs
+// when the tool runs, an In value, a JSON string or a decoded map, is
+// checked against that schema and decoded into an In before the handler
+// is called with the run's context.
+func NewTool[In, Out any](name, description string, handler func(context.Context, In) (Out, error)) (Tool, error) {
+	fields, err := SchemaFor[In]()
+	if err != nil {
+		return Tool{}, fmt.Errorf("tool %s: %w", name, err)
+	}
+	var zero In
+	return Tool{
+		Name:        name,
+		Description: description,
+		Inputs:      zero,
+		Schema:      (&JSONSchemaBuilder{Fields: fields}).Build(),
+		HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+			input, err := decodeToolInput[In](name, fields, inputs)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, input)
+		},
+	}, nil
+}
+
+// MustTool is NewTool for input types known to be valid, such as the
+// built-in tools'. It panics if the schema cannot be derived.
+func MustTool[In, Out any](name, description string, handler func(context.Context, In) (Out, error)) Tool {
+	tool, err := NewTool(name, description, handler)
+	if err != nil {
+		panic(err)
+	}
+	return tool
+}
+
+// decodeToolInput converts raw tool arguments into an In, validating them
+// against fields first.
+func decodeToolInput[In any](name string, fields []SchemaField, inputs interface{}) (In, error) {
+	var input In
+	if v, ok := inputs.(*In); ok && v != nil {
+		inputs = *v
+	}
+
+	var value interface{}
+	switch v := inputs.(type) {
+	case string:
+		if v == "" {
+			v = "{}"
+		}
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			return input, &ToolInputError{Tool: name, Err: fmt.Errorf("arguments are not valid JSON: %v", err)}
+		}
+	case []byte:
+		if err := json.Unmarshal(v, &value); err != nil {
+			return input, &ToolInputError{Tool: name, Err: fmt.Errorf("arguments are not valid JSON: %v", err)}
+		}
+	case nil:
+		value = map[string]interface{}{}
+	default:
+		// Go values, an In or a map from a parsed response, are normalised
+		// to the types encoding/json produces before validation
+		data, err := json.Marshal(v)
+		if err != nil {
+			return input, &ToolInputError{Tool: name, Err: err}
+		}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return input, &ToolInputError{Tool: name, Err: err}
+		}
+	}
+
+	if violations := ValidateFields(fields, value); len(violations) > 0 {
+		return input, &ToolInputError{Tool: name, Err: &ValidationError{Violations: violations}}
+	}
+	input, err := Decode[In](value)
+	if err != nil {
+		return input, &ToolInputError{Tool: name, Err: err}
+	}
+	return input, nil
+}
@@ -0,0 +1,61 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type lookupInput struct {
+	Domain string `json:"domain" format:"hostname"`
+	Limit  int    `json:"limit,omitempty" minimum:"1"`
+}
+
+func TestNewToolDecodesInput(t *testing.T) {
+	tool, err := NewTool("lookup", "Looks up a domain", func(ctx context.Context, in lookupInput) (string, error) {
+		return in.Domain, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inputs := range []interface{}{
+		`{"domain": "example.com"}`,
+		[]byte(`{"domain": "example.com"}`),
+		map[string]interface{}{"domain": "example.com"},
+		lookupInput{Domain: "example.com"},
+		&lookupInput{Domain: "example.com"},
+	} {
+		tool.Inputs = inputs
+		if got, err := tool.Run(context.Background()); err != nil || got != "example.com" {
+			t.Errorf("Run with %T = %v, %v", inputs, got, err)
+		}
+	}
+}
+
+func TestNewToolRejectsInvalidInput(t *testing.T) {
+	tool := MustTool("lookup", "Looks up a domain", func(ctx context.Context, in lookupInput) (string, error) {
+		t.Error("handler called with invalid input")
+		return "", nil
+	})
+	tool.Inputs = `{"limit": 0}`
+	_, err := tool.Run(context.Background())
+
+	var inputErr *ToolInputError
+	if !errors.As(err, &inputErr) {
+		t.Fatalf("Run error = %v, want a *ToolInputError", err)
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Violations) != 2 {
+		t.Errorf("violations = %v, want missing domain and limit below minimum", verr)
+	}
+	msg := inputErr.Error()
+	if !strings.Contains(msg, "arguments do not match the tool's input schema") || strings.Contains(msg, "output") {
+		t.Errorf("message = %q, want it to describe the tool's arguments", msg)
+	}
+
+	tool.Inputs = `{not json`
+	if _, err := tool.Run(context.Background()); !errors.As(err, &inputErr) {
+		t.Errorf("Run with malformed JSON = %v, want a *ToolInputError", err)
+	}
+}
@@ -0,0 +1,309 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation is one way a value fails its schema.
+type Violation struct {
+	// Path is a JSON Pointer (RFC 6901) to the offending value; the empty
+	// string is the whole document.
+	Path string `json:"path"`
+	// Keyword is the JSON Schema keyword that failed, such as "type",
+	// "required" or "enum".
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + v.Message
+}
+
+// ValidationError lists every violation found in a parsed output.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
+	}
+	return "output does not match schema: " + strings.Join(parts, "; ")
+}
+
+// ValidateFields checks a decoded JSON object against fields and returns
+// every violation. Optional fields may be null.
+func ValidateFields(fields []SchemaField, value interface{}) []Violation {
+	var violations []Violation
+	validateObject("", fields, nil, value, &violations)
+	return violations
+}
+
+// Validate checks a decoded JSON value against the field's schema.
+func (f SchemaField) Validate(value interface{}) []Violation {
+	var violations []Violation
+	f.validate("", value, &violations)
+	return violations
+}
+
+func (f SchemaField) validate(path string, value interface{}, out *[]Violation) {
+	add := func(keyword, format string, args ...interface{}) {
+		*out = append(*out, Violation{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !matchesType(f.Type, value) {
+		add("type", "expected %s, got %s", f.Type, jsonType(value))
+		return
+	}
+
+	if len(f.Enum) > 0 && !inEnum(f.Enum, value) {
+		add("enum", "%s is not one of %s", compactJSON(value), compactJSON(f.Enum))
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := int64(utf8.RuneCountInString(v))
+		if f.MinLength != nil && length < *f.MinLength {
+			add("minLength", "length %d is less than %d", length, *f.MinLength)
+		}
+		if f.MaxLength != nil && length > *f.MaxLength {
+			add("maxLength", "length %d is more than %d", length, *f.MaxLength)
+		}
+		if f.Pattern != "" {
+			re, err := compilePattern(f.Pattern)
+			if err != nil {
+				add("pattern", "schema pattern %q is invalid: %v", f.Pattern, err)
+			} else if !re.MatchString(v) {
+				add("pattern", "%q does not match %q", v, f.Pattern)
+			}
+		}
+		if f.Format != "" && !matchesFormat(f.Format, v) {
+			add("format", "%q is not a valid %s", v, f.Format)
+		}
+
+	case float64:
+		if f.Minimum != nil && v < *f.Minimum {
+			add("minimum", "%v is less than %v", v, *f.Minimum)
+		}
+		if f.Maximum != nil && v > *f.Maximum {
+			add("maximum", "%v is more than %v", v, *f.Maximum)
+		}
+		if f.ExclusiveMinimum != nil && v <= *f.ExclusiveMinimum {
+			add("exclusiveMinimum", "%v is not more than %v", v, *f.ExclusiveMinimum)
+		}
+		if f.ExclusiveMaximum != nil && v >= *f.ExclusiveMaximum {
+			add("exclusiveMaximum", "%v is not less than %v", v, *f.ExclusiveMaximum)
+		}
+
+	case []interface{}:
+		count := int64(len(v))
+		if f.MinItems != nil && count < *f.MinItems {
+			add("minItems", "has %d items, fewer than %d", count, *f.MinItems)
+		}
+		if f.MaxItems != nil && count > *f.MaxItems {
+			add("maxItems", "has %d items, more than %d", count, *f.MaxItems)
+		}
+		if f.UniqueItems {
+			seen := make(map[string]int, len(v))
+			for i, item := range v {
+				key := compactJSON(item)
+				if first, ok := seen[key]; ok {
+					add("uniqueItems", "items %d and %d are equal", first, i)
+				} else {
+					seen[key] = i
+				}
+			}
+		}
+		if f.Items != nil {
+			for i, item := range v {
+				f.Items.validate(path+"/"+strconv.Itoa(i), item, out)
+			}
+		}
+
+	case map[string]interface{}:
+		validateObject(path, f.Properties, f.AdditionalProperties, v, out)
+	}
+}
+
+// compiledPattern is a schema pattern, or why it does not compile.
+type compiledPattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+var patternCache sync.Map // string -> compiledPattern
+
+// compilePattern compiles a schema pattern once; schemas are validated
+// again for every response, usually with the same few patterns.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := patternCache.Load(pattern); ok {
+		c := cached.(compiledPattern)
+		return c.re, c.err
+	}
+	re, err := regexp.Compile(pattern)
+	patternCache.Store(pattern, compiledPattern{re, err})
+	return re, err
+}
+
+func validateObject(path string, fields []SchemaField, additional *bool, value interface{}, out *[]Violation) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		*out = append(*out, Violation{Path: path, Keyword: "type", Message: fmt.Sprintf("expected object, got %s", jsonType(value))})
+		return
+	}
+
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field.Field] = true
+		fieldPath := path + "/" + escapePointer(field.Field)
+		v, present := object[field.Field]
+		if !present {
+			if field.Required {
+				*out = append(*out, Violation{Path: fieldPath, Keyword: "required", Message: "missing required field: " + field.Field})
+			}
+			continue
+		}
+		if v == nil && !field.Required {
+			continue
+		}
+		field.validate(fieldPath, v, out)
+	}
+
+	if additional != nil && !*additional {
+		for key := range object {
+			if !known[key] {
+				*out = append(*out, Violation{Path: path + "/" + escapePointer(key), Keyword: "additionalProperties", Message: "unexpected field: " + key})
+			}
+		}
+	}
+}
+
+func matchesType(typ string, value interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n) && !math.IsInf(n, 0)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	// No type, or one JSON Schema does not define, accepts anything
+	return true
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", truncateForMessage(v))
+	case float64:
+		return "number " + strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return "boolean " + strconv.FormatBool(v)
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func truncateForMessage(s string) string {
+	if utf8.RuneCountInString(s) <= 40 {
+		return s
+	}
+	return string([]rune(s)[:40]) + "..."
+}
+
+// inEnum compares by JSON encoding so enum values loaded from YAML or Go
+// (ints, say) match the float64s encoding/json produces.
+func inEnum(enum []interface{}, value interface{}) bool {
+	encoded := compactJSON(value)
+	for _, allowed := range enum {
+		if compactJSON(allowed) == encoded {
+			return true
+		}
+	}
+	return false
+}
+
+func compactJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hostnamePattern = regexp.MustCompile(`^(?i:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?)(?:\.(?i:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?))*\.?$`)
+)
+
+// matchesFormat checks the common JSON Schema formats. Unknown formats
+// are annotations only and always pass.
+func matchesFormat(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	case "time":
+		_, err := time.Parse("15:04:05Z07:00", s)
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uri", "url":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	case "ipv6":
+		ip := net.ParseIP(s)
+		return ip != nil && strings.Contains(s, ":")
+	case "hostname":
+		return len(s) <= 253 && hostnamePattern.MatchString(s)
+	}
+	return true
+}
+
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,156 @@
+package components
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValidateFields(t *testing.T) {
+	minScore, maxScore := 0.0, 10.0
+	minItems, maxLength := int64(1), int64(5)
+	closed := false
+	fields := []SchemaField{
+		{Field: "isComplete", Type: "boolean", Required: true},
+		{Field: "risk", Type: "string", Enum: []interface{}{"low", "high"}},
+		{Field: "score", Type: "number", Minimum: &minScore, Maximum: &maxScore},
+		{Field: "count", Type: "integer"},
+		{Field: "code", Type: "string", Pattern: `^[A-Z]{3}$`, MaxLength: &maxLength},
+		{Field: "contact", Type: "object", AdditionalProperties: &closed, Properties: []SchemaField{
+			{Field: "email", Type: "string", Format: "email", Required: true},
+			{Field: "site", Type: "string", Format: "uri"},
+			{Field: "a/b~c", Type: "string"},
+		}},
+		{Field: "findings", Type: "array", MinItems: &minItems, Items: &SchemaField{Type: "object", Properties: []SchemaField{
+			{Field: "host", Type: "string", Format: "hostname", Required: true},
+			{Field: "ips", Type: "array", Items: &SchemaField{Type: "string", Format: "ipv4"}},
+		}}},
+	}
+
+	type violation struct{ Path, Keyword string }
+	tests := []struct {
+		name  string
+		input string
+		want  []violation
+	}{
+		{
+			name:  "valid",
+			input: `{"isComplete": true, "risk": "low", "score": 10, "count": 3, "code": "ABC", "contact": {"email": "a@example.com", "site": "https://example.com"}, "findings": [{"host": "example.com", "ips": ["192.0.2.1"]}]}`,
+		},
+		{
+			name:  "optional fields may be null",
+			input: `{"isComplete": false, "risk": null, "contact": null}`,
+		},
+		{
+			name:  "boolean given as a string",
+			input: `{"isComplete": "true"}`,
+			want:  []violation{{"/isComplete", "type"}},
+		},
+		{
+			name:  "missing required field",
+			input: `{}`,
+			want:  []violation{{"/isComplete", "required"}},
+		},
+		{
+			name:  "required field may not be null",
+			input: `{"isComplete": null}`,
+			want:  []violation{{"/isComplete", "type"}},
+		},
+		{
+			name:  "enum",
+			input: `{"isComplete": true, "risk": "medium"}`,
+			want:  []violation{{"/risk", "enum"}},
+		},
+		{
+			name:  "bounds",
+			input: `{"isComplete": true, "score": -1}`,
+			want:  []violation{{"/score", "minimum"}},
+		},
+		{
+			name:  "integer",
+			input: `{"isComplete": true, "count": 1.5}`,
+			want:  []violation{{"/count", "type"}},
+		},
+		{
+			name:  "pattern and length",
+			input: `{"isComplete": true, "code": "abcdef"}`,
+			want:  []violation{{"/code", "maxLength"}, {"/code", "pattern"}},
+		},
+		{
+			name:  "nested object",
+			input: `{"isComplete": true, "contact": {"site": "example.com", "phone": "1", "a/b~c": 1}}`,
+			want:  []violation{{"/contact/email", "required"}, {"/contact/site", "format"}, {"/contact/a~1b~0c", "type"}, {"/contact/phone", "additionalProperties"}},
+		},
+		{
+			name:  "objects in arrays",
+			input: `{"isComplete": true, "findings": [{"host": "example.com"}, {"host": "bad host", "ips": ["192.0.2.1", "::1", 7]}]}`,
+			want:  []violation{{"/findings/1/host", "format"}, {"/findings/1/ips/1", "format"}, {"/findings/1/ips/2", "type"}},
+		},
+		{
+			name:  "array length",
+			input: `{"isComplete": true, "findings": []}`,
+			want:  []violation{{"/findings", "minItems"}},
+		},
+		{
+			name:  "not an object",
+			input: `[1, 2]`,
+			want:  []violation{{"", "type"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.input), &value); err != nil {
+				t.Fatal(err)
+			}
+			var got []violation
+			for _, v := range ValidateFields(fields, value) {
+				got = append(got, violation{v.Path, v.Keyword})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesFormat(t *testing.T) {
+	tests := []struct {
+		format, value string
+		want          bool
+	}{
+		{"date-time", "2024-05-01T10:00:00Z", true},
+		{"date-time", "2024-05-01 10:00", false},
+		{"date", "2024-05-01", true},
+		{"date", "01/05/2024", false},
+		{"time", "10:00:00+02:00", true},
+		{"email", "a@example.com", true},
+		{"email", "Alice <a@example.com>", false},
+		{"uri", "https://example.com/a", true},
+		{"uri", "/relative", false},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", true},
+		{"uuid", "123e4567", false},
+		{"ipv4", "192.0.2.1", true},
+		{"ipv4", "::ffff:192.0.2.1", false},
+		{"ipv6", "2001:db8::1", true},
+		{"ipv6", "192.0.2.1", false},
+		{"hostname", "mail.example.com", true},
+		{"hostname", "-bad.example.com", false},
+		{"unknown-format", "anything", true},
+	}
+	for _, tt := range tests {
+		if got := matchesFormat(tt.format, tt.value); got != tt.want {
+			t.Errorf("matchesFormat(%q, %q) = %v, want %v", tt.format, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidPatternIsReported(t *testing.T) {
+	field := SchemaField{Type: "string", Pattern: "("}
+	for i := 0; i < 2; i++ {
+		violations := field.Validate("x")
+		if len(violations) != 1 || violations[0].Keyword != "pattern" {
+			t.Errorf("run %d: violations = %v, want one pattern violation", i, violations)
+		}
+	}
+}
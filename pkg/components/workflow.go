@@ -2,7 +2,9 @@ package components
 
 import (
     "context"
+    "errors"
     "fmt"
+    "strings"
     "time"
     "encoding/json"
 )
@@ -12,6 +14,9 @@ type WorkFlowType int
 const (
     WorkFlowDo WorkFlowType = iota
     WorkFlowChoose
+    // WorkFlowClassify answers with one of OutputFormat.Labels and returns
+    // a *Classification with a confidence score.
+    WorkFlowClassify
 )
 
 type WorkFlow struct {
@@ -23,22 +28,130 @@ type WorkFlow struct {
     Prompt       Prompt
     Tools        *ToolList
     Logger       *Logger
+    // Experiment, when set, picks the prompt for each run from its
+    // variants and records the outcome.
+    Experiment   *Experiment
+    // AssignmentKey keeps a user or session on one variant.
+    AssignmentKey string
+    // LastRun describes the most recent run, for attaching feedback.
+    LastRun      *RunRecord
+    // OnStreamEvent, when set and the client supports streaming, receives
+    // StreamParser events while the response is generated, so fields can
+    // be shown before the output is complete. The finished response is
+    // still parsed and validated as usual.
+    OnStreamEvent func(StreamEvent)
+    // Guardrails check each parsed result in order. Rejections are sent
+    // back for correction like parse errors; repairs and annotations are
+    // kept in LastRun.
+    Guardrails   []Guardrail
 }
 
 type WorkflowConfig struct {
     MaxRetries   int
     Timeout      time.Duration
+    // Temperature is kept for compatibility; a non-zero value is used when
+    // Params.Temperature is unset.
     Temperature  float64
+    // Params overrides the client's sampling defaults for this workflow.
+    Params       GenerationParams
+    // MaxContinuations is how many follow-up requests Run may issue when a
+    // response stops on the length limit. Zero disables continuation.
+    MaxContinuations int
+    // MaxCorrections is how many times Run may send a rejected response
+    // back with the parser's errors and ask for a corrected one. Zero
+    // disables correction.
+    MaxCorrections int
+    // Samples is how many answers WorkFlowClassify draws to estimate
+    // confidence when the client cannot report log probabilities. Zero
+    // means five.
+    Samples      int
 }
 
+// ContinuationMessage is sent after a truncated response to ask the model
+// to carry on.
+const ContinuationMessage = "Your previous response was cut off. Continue exactly where it stopped. Do not repeat any text you have already written and do not add any commentary."
 
+// CorrectionMessage introduces the errors sent back after a response the
+// OutputParser rejected.
+const CorrectionMessage = "Your previous response could not be used because of these errors:"
+
+// Continuations are checked for text the model repeated from the end of
+// the previous piece. Shorter overlaps are treated as coincidence.
+const (
+    minStitchOverlap = 10
+    maxStitchOverlap = 200
+)
+
+// GenerationParams returns the workflow-level overrides.
+func (c WorkflowConfig) GenerationParams() GenerationParams {
+    params := c.Params
+    if params.Temperature == nil && c.Temperature != 0 {
+        params.Temperature = Float(c.Temperature)
+    }
+    return params
+}
 
 func (wf *WorkFlow) Run(ctx context.Context) (interface{}, error) {
     // Log start of workflow
     wf.Logger.LogItem(wf.Name, "Starting workflow execution")
-    
+
+    run := NewRunRecord(wf.Name)
+    run.Key = wf.AssignmentKey
+    wf.LastRun = run
+
+    prompt := wf.Prompt
+    if wf.Experiment != nil {
+        variant, err := wf.Experiment.Assign(wf.AssignmentKey)
+        if err != nil {
+            return nil, fmt.Errorf("variant assignment failed: %w", err)
+        }
+        wf.Logger.LogItem(wf.Name, fmt.Sprintf("Serving variant %s of %s", variant.Name, wf.Experiment.Name))
+        run.Experiment = wf.Experiment.Name
+        run.Variant = variant.Name
+        prompt = variant.Prompt
+    }
+
+    result, err := wf.run(ctx, prompt, run)
+    run.Latency = time.Since(run.Started)
+    if err != nil {
+        run.Error = err.Error()
+    }
+    if wf.Experiment != nil && wf.Experiment.Recorder != nil {
+        if recErr := wf.Experiment.Recorder.Record(*run); recErr != nil {
+            wf.Logger.LogItem(wf.Name, fmt.Sprintf("Error recording run: %v", recErr))
+        }
+    }
+    return result, err
+}
+
+// run renders, generates and parses one prompt, filling in run as it goes.
+func (wf *WorkFlow) run(ctx context.Context, prompt Prompt, run *RunRecord) (interface{}, error) {
+    // Render templates unless the caller already has
+    prompt, err := prompt.RenderContext(ctx)
+    if err != nil {
+        wf.Logger.LogItem(wf.Name, fmt.Sprintf("Error rendering prompt: %v", err))
+        return nil, fmt.Errorf("prompt rendering failed: %w", err)
+    }
+    for _, finding := range prompt.InjectionFindings {
+        wf.Logger.LogItem(wf.Name, fmt.Sprintf("Possible prompt injection in %s (%s): %q", finding.Variable, finding.Pattern, finding.Excerpt))
+    }
+
+    // Prompt-level parameters win over the workflow's
+    prompt.Params = wf.Config.GenerationParams().Merge(prompt.Params)
+
+    // Classification makes its own requests to measure confidence
+    if wf.Type == WorkFlowClassify {
+        result, err := wf.classify(ctx, prompt, run)
+        if err != nil {
+            wf.Logger.LogItem(wf.Name, fmt.Sprintf("Error classifying response: %v", err))
+            return nil, fmt.Errorf("classification failed: %w", err)
+        }
+        run.ParseOK = true
+        return result, nil
+    }
+
     // Generate LLM response
-    response, err := wf.Client.Generate(ctx, wf.Prompt)
+    response, err := wf.generate(ctx, prompt, run)
     if err != nil {
         wf.Logger.LogItem(wf.Name, fmt.Sprintf("Error generating response: %v", err))
         return nil, fmt.Errorf("LLM generation failed: %w", err)
@@ -48,19 +161,39 @@ func (wf *WorkFlow) Run(ctx context.Context) (interface{}, error) {
     // Parse the response based on workflow type
     switch wf.Type {
     case WorkFlowDo:
-        result, err := wf.OutputParser.Parse(response)
+        result, err := wf.parse(response, run)
+        for i := 0; err != nil && i < wf.Config.MaxCorrections; i++ {
+            wf.Logger.LogItem(wf.Name, fmt.Sprintf("Error parsing response, requesting correction %d: %v", i+1, err))
+
+            prompt.Turns = append(append([]Message{}, prompt.Turns...),
+                Message{Role: RoleAssistant, Content: response},
+                Message{Role: RoleUser, Content: correctionMessage(err)},
+            )
+            if wf.OnStreamEvent != nil {
+                wf.OnStreamEvent(StreamEvent{Type: StreamReset})
+            }
+            response, err = wf.generate(ctx, prompt, run)
+            if err != nil {
+                wf.Logger.LogItem(wf.Name, fmt.Sprintf("Error generating correction: %v", err))
+                return nil, fmt.Errorf("correction %d failed: %w", i+1, err)
+            }
+            result, err = wf.parse(response, run)
+        }
         if err != nil {
             wf.Logger.LogItem(wf.Name, fmt.Sprintf("Error parsing response: %v", err))
             return nil, fmt.Errorf("output parsing failed: %w", err)
         }
+        run.ParseOK = true
         return result, nil
         
     case WorkFlowChoose:
         var toolSelection ToolSelectionOutput
-        if err := json.Unmarshal([]byte(response), &toolSelection); err != nil {
+        cleaned, _ := RepairJSON(response)
+        if err := json.Unmarshal([]byte(cleaned), &toolSelection); err != nil {
             wf.Logger.LogItem(wf.Name, fmt.Sprintf("Error parsing tool selection: %v", err))
             return nil, fmt.Errorf("tool selection parsing failed: %w", err)
         }
+        run.ParseOK = true
         
         tool, exists := wf.Tools.Tools[toolSelection.ToolName]
         if !exists {
@@ -68,7 +201,7 @@ func (wf *WorkFlow) Run(ctx context.Context) (interface{}, error) {
         }
         
         tool.Inputs = toolSelection.ToolInputs
-        result, err := tool.Run()
+        result, err := tool.Run(ctx)
         if err != nil {
             wf.Logger.LogItem(wf.Name, fmt.Sprintf("Error running tool: %v", err))
             return nil, fmt.Errorf("tool execution failed: %w", err)
@@ -79,6 +212,138 @@ func (wf *WorkFlow) Run(ctx context.Context) (interface{}, error) {
     return nil, fmt.Errorf("invalid workflow type")
 }
 
+// parse checks a response with the client, parses it and applies the
+// guardrails, recording the attempt in run.
+func (wf *WorkFlow) parse(response string, run *RunRecord) (interface{}, error) {
+    if err := wf.Client.ValidateResponse(response); err != nil {
+        run.addAttempt(response, err, nil)
+        return nil, err
+    }
+    result, err := wf.OutputParser.Parse(response)
+    var notes []GuardrailViolation
+    if err == nil && len(wf.Guardrails) > 0 {
+        result, notes, err = ApplyGuardrails(result, wf.Guardrails)
+        for _, note := range notes {
+            wf.Logger.LogItem(wf.Name, fmt.Sprintf("Guardrail %s: %s", note.Action, note))
+        }
+    }
+    run.addAttempt(response, err, notes)
+    return result, err
+}
+
+// generate runs the prompt and, when MaxContinuations allows, keeps asking
+// the model to continue while it stops on the length limit. The pieces are
+// stitched together before the caller parses them.
+func (wf *WorkFlow) generate(ctx context.Context, prompt Prompt, run *RunRecord) (string, error) {
+    var onDelta func(string) error
+    if wf.OnStreamEvent != nil {
+        // One parser spans continuations, which carry on the same document
+        parser := NewStreamParser(nil)
+        onDelta = func(delta string) error {
+            for _, event := range parser.Write(delta) {
+                wf.OnStreamEvent(event)
+            }
+            return nil
+        }
+    }
+
+    choice, err := wf.complete(ctx, prompt, run, onDelta)
+    if err != nil {
+        return "", err
+    }
+    response := choice.Content
+
+    for i := 0; choice.FinishReason == FinishReasonLength && i < wf.Config.MaxContinuations; i++ {
+        wf.Logger.LogItem(wf.Name, fmt.Sprintf("Response hit the length limit, requesting continuation %d", i+1))
+
+        next := prompt
+        next.Turns = append(append([]Message{}, prompt.Turns...),
+            Message{Role: RoleAssistant, Content: response},
+            Message{Role: RoleUser, Content: ContinuationMessage},
+        )
+        // The continuation is the rest of a document, not a new one
+        next.OutputFormat.NoGrammar = true
+        choice, err = wf.complete(ctx, next, run, onDelta)
+        if err != nil {
+            return "", fmt.Errorf("continuation %d failed: %w", i+1, err)
+        }
+        response = stitch(response, choice.Content)
+    }
+
+    if choice.FinishReason == FinishReasonLength {
+        wf.Logger.LogItem(wf.Name, "Response is still truncated at the length limit")
+    }
+    return response, nil
+}
+
+// complete returns the first candidate for the prompt and adds its usage
+// to run. When onDelta is set and the client can stream, the response is
+// streamed through it.
+func (wf *WorkFlow) complete(ctx context.Context, prompt Prompt, run *RunRecord, onDelta func(string) error) (Choice, error) {
+    completion, err := wf.completion(ctx, prompt, run, onDelta)
+    if err != nil {
+        return Choice{}, err
+    }
+    return completion.Choices[0], nil
+}
+
+// completion is complete returning every candidate. It fails rather than
+// return a completion without choices.
+func (wf *WorkFlow) completion(ctx context.Context, prompt Prompt, run *RunRecord, onDelta func(string) error) (*Completion, error) {
+    var completion *Completion
+    var err error
+    if streamer, ok := wf.Client.(StreamingClient); ok && onDelta != nil {
+        completion, err = streamer.Stream(ctx, prompt, onDelta)
+    } else {
+        completion, err = wf.Client.Complete(ctx, prompt)
+    }
+    if err != nil {
+        return nil, err
+    }
+    model := completion.Model
+    if model == "" {
+        model = wf.Client.GetModelInfo().Model
+    }
+    run.AddUsage(model, completion.Usage)
+    if len(completion.Choices) == 0 {
+        return nil, &LLMError{Kind: ErrEmptyResponse, Provider: wf.Client.GetModelInfo().Provider}
+    }
+    return completion, nil
+}
+
+// correctionMessage lists a parse failure for the model to fix.
+func correctionMessage(err error) string {
+    var b strings.Builder
+    b.WriteString(CorrectionMessage)
+    var verr *ValidationError
+    var gerr *GuardrailError
+    if errors.As(err, &verr) {
+        for _, v := range verr.Violations {
+            b.WriteString("\n- " + v.String())
+        }
+    } else if errors.As(err, &gerr) {
+        for _, v := range gerr.Violations {
+            b.WriteString("\n- " + v.String())
+        }
+    } else {
+        b.WriteString("\n- " + err.Error())
+    }
+    b.WriteString("\nReturn the complete corrected response in the required format, with no other text.")
+    return b.String()
+}
+
+// stitch appends next to prev, dropping any prefix of next that repeats
+// the end of prev.
+func stitch(prev, next string) string {
+    limit := min(len(prev), len(next), maxStitchOverlap)
+    for n := limit; n >= minStitchOverlap; n-- {
+        if strings.HasSuffix(prev, next[:n]) {
+            return prev + next[n:]
+        }
+    }
+    return prev + next
+}
+
 func NewWorkflow(
     name string,
     workflowType WorkFlowType,
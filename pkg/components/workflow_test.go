@@ -0,0 +1,78 @@
+package components
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// fakeClient replays canned choices and records every prompt it is sent.
+type fakeClient struct {
+	mu      sync.Mutex
+	choices []Choice
+	prompts []Prompt
+}
+
+func (c *fakeClient) Generate(ctx context.Context, prompt Prompt) (string, error) {
+	completion, err := c.Complete(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return completion.Choices[0].Content, nil
+}
+
+func (c *fakeClient) Complete(ctx context.Context, prompt Prompt) (*Completion, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prompts = append(c.prompts, prompt)
+	if len(c.choices) == 0 {
+		return &Completion{Choices: []Choice{{Content: "{}", FinishReason: FinishReasonStop}}}, nil
+	}
+	choice := c.choices[0]
+	c.choices = c.choices[1:]
+	if choice.FinishReason == "" {
+		choice.FinishReason = FinishReasonStop
+	}
+	return &Completion{Choices: []Choice{choice}}, nil
+}
+
+func (c *fakeClient) GetModelInfo() ModelInfo {
+	return ModelInfo{Provider: "fake", Model: "fake-model"}
+}
+
+func (c *fakeClient) ValidateResponse(response string) error {
+	return nil
+}
+
+func TestContinuationDropsGrammar(t *testing.T) {
+	client := &fakeClient{choices: []Choice{
+		{Content: `{"name": "ex`, FinishReason: FinishReasonLength},
+		{Content: `ample"}`},
+	}}
+	fields := []SchemaField{{Field: "name", Type: "string", Required: true}}
+	prompt := Prompt{
+		UserMessage:  "Name something",
+		OutputFormat: OutputFormat{Type: OutputJSON, Fields: fields},
+	}
+	wf, err := NewWorkflow("test", WorkFlowDo, client, NewJSONParser(fields), WorkflowConfig{MaxContinuations: 1}, prompt, nil, &Logger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := wf.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.(map[string]interface{})["name"]; got != "example" {
+		t.Errorf("name = %v, want example", got)
+	}
+
+	if len(client.prompts) != 2 {
+		t.Fatalf("sent %d prompts, want 2", len(client.prompts))
+	}
+	if grammar, _ := client.prompts[0].OutputFormat.GBNF(); grammar == "" {
+		t.Error("first request has no grammar")
+	}
+	if grammar, _ := client.prompts[1].OutputFormat.GBNF(); grammar != "" {
+		t.Errorf("continuation request has grammar %q", grammar)
+	}
+}
@@ -6,60 +6,187 @@ import (
 	"fmt"
 	"goflow/pkg/components"
 	"goflow/pkg/llms/openai"
+	"strings"
 	"time"
 	// "goflow/pkg/tools"
 )
 
-func CoTWorkFlow(client *openai.OpenAIClient, sysMessage string, uMessage string, fields []components.SchemaField, variables map[string]interface{}, tools *components.ToolList) (interface{}, error) {
+// CoTOption configures optional behaviour of CoTWorkFlow.
+type CoTOption func(*cotConfig)
+
+type cotConfig struct {
+	planningParams components.GenerationParams
+	finalParams    components.GenerationParams
+	finalClient    components.LLMClient
+	// finalContinuations caps continuation requests for the final step.
+	finalContinuations int
+	maxCorrections     int
+	finalStream        func(components.StreamEvent)
+	experiment         *components.Experiment
+	assignmentKey      string
+	toolWorkers        int
+}
+
+// defaultCorrections is how many times a step may ask the model to fix
+// output that failed validation before the flow gives up.
+const defaultCorrections = 2
+
+// stepConfig returns the workflow config for a single step.
+func stepConfig(params components.GenerationParams, continuations int, corrections int) components.WorkflowConfig {
+	return components.WorkflowConfig{
+		MaxRetries:       3,
+		Timeout:          time.Second * 30,
+		Params:           params,
+		MaxContinuations: continuations,
+		MaxCorrections:   corrections,
+	}
+}
+
+// WithPlanningParams sets the sampling parameters used for every tool-selection step.
+func WithPlanningParams(params components.GenerationParams) CoTOption {
+	return func(c *cotConfig) {
+		c.planningParams = params
+	}
+}
+
+// WithFinalParams sets the sampling parameters used for the final write-up.
+func WithFinalParams(params components.GenerationParams) CoTOption {
+	return func(c *cotConfig) {
+		c.finalParams = params
+	}
+}
+
+// WithFinalClient runs the final synthesis step on a different client, for
+// example a reasoning model, while the tool-selection steps keep the main one.
+func WithFinalClient(client components.LLMClient) CoTOption {
+	return func(c *cotConfig) {
+		c.finalClient = client
+	}
+}
+
+// WithFinalContinuations lets the final step request up to n continuations
+// when its output hits the token limit, so long reports are not cut off
+// mid-JSON.
+func WithFinalContinuations(n int) CoTOption {
+	return func(c *cotConfig) {
+		c.finalContinuations = n
+	}
+}
+
+// WithMaxCorrections sets how many times each step may send invalid
+// output back to the model with the validation errors. Zero disables
+// correction; the default is 2.
+func WithMaxCorrections(n int) CoTOption {
+	return func(c *cotConfig) {
+		c.maxCorrections = n
+	}
+}
+
+// WithFinalStream streams the final step and passes its parse events to
+// onEvent, so a report field such as the analysis can be shown while it
+// is written. The final output is still validated once complete.
+func WithFinalStream(onEvent func(components.StreamEvent)) CoTOption {
+	return func(c *cotConfig) {
+		c.finalStream = onEvent
+	}
+}
+
+// WithExperiment splits CoTWorkFlow runs between system prompt variants.
+// Each variant's Prompt.SystemMessage replaces sysMessage for the
+// tool-selection steps; the rest of the variant prompt is ignored. key
+// keeps a user or session on one variant. The whole run, across all
+// steps, is recorded as one RunRecord. As with WorkFlow.Run, a record that
+// cannot be stored is logged and does not fail the flow.
+func WithExperiment(experiment *components.Experiment, key string) CoTOption {
+	return func(c *cotConfig) {
+		c.experiment = experiment
+		c.assignmentKey = key
+	}
+}
+
+// WithToolWorkers caps how many of a step's tool calls run at once. The
+// default is components.DefaultToolWorkers.
+func WithToolWorkers(n int) CoTOption {
+	return func(c *cotConfig) {
+		c.toolWorkers = n
+	}
+}
+
+// CoTWorkFlow runs the tool-selection steps and the final write-up under
+// ctx. Cancelling it, or reaching its deadline, stops the current model
+// request or tool run and ends the flow with ctx's error.
+func CoTWorkFlow(ctx context.Context, client *openai.OpenAIClient, sysMessage string, uMessage string, fields []components.SchemaField, variables map[string]interface{}, tools *components.ToolList, opts ...CoTOption) (interface{}, error) {
+	config := &cotConfig{finalClient: client, maxCorrections: defaultCorrections}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	run := components.NewRunRecord("CoTWorkFlow")
+	run.Key = config.assignmentKey
+	if config.experiment != nil {
+		variant, err := config.experiment.Assign(config.assignmentKey)
+		if err != nil {
+			return nil, fmt.Errorf("variant assignment failed: %w", err)
+		}
+		run.Experiment = config.experiment.Name
+		run.Variant = variant.Name
+		sysMessage = variant.Prompt.SystemMessage
+	}
+
+	result, err := runCoT(ctx, client, sysMessage, uMessage, fields, variables, tools, config, run)
+	run.Latency = time.Since(run.Started)
+	if err != nil {
+		run.Error = err.Error()
+	} else {
+		run.ParseOK = true
+	}
+	if config.experiment != nil && config.experiment.Recorder != nil {
+		if recErr := config.experiment.Recorder.Record(*run); recErr != nil {
+			(&components.Logger{LogFile: "workflow.log"}).LogItem("CoTWorkFlow", fmt.Sprintf("Error recording run: %v", recErr))
+		}
+	}
+	return result, err
+}
+
+// stepDecision is the output of each tool-selection step. A step makes
+// either one call through tool_name or several independent ones through
+// tool_calls, which run in parallel.
+type stepDecision struct {
+	ToolName     string                 `json:"tool_name,omitempty" description:"Name of the tool to use for a single call"`
+	ToolInput    map[string]interface{} `json:"tool_input,omitempty" description:"Input for the selected tool"`
+	ToolCalls    []stepToolCall         `json:"tool_calls,omitempty" description:"Several independent tool calls to run at the same time instead of tool_name, e.g. whois on three domains"`
+	IsComplete   bool                   `json:"isComplete" description:"Whether the task is complete, must be 'true' or 'false'"`
+	NextStep     string                 `json:"nextStep,omitempty" description:"The next step to take if the task is not complete"`
+	Thought      string                 `json:"thought,omitempty" description:"This section is to capture your thoughts on the current task that can carry over to the next"`
+	WorkflowName string                 `json:"workflowName" description:"Name for the next workflow"`
+}
+
+type stepToolCall struct {
+	ID        string                 `json:"id" description:"Unique ID for this call, e.g. call_1"`
+	ToolName  string                 `json:"tool_name" description:"Name of the tool to use"`
+	ToolInput map[string]interface{} `json:"tool_input" description:"Input for the tool"`
+}
+
+func runCoT(ctx context.Context, client components.LLMClient, sysMessage string, uMessage string, fields []components.SchemaField, variables map[string]interface{}, tools *components.ToolList, config *cotConfig, run *components.RunRecord) (interface{}, error) {
+	stepFields, err := components.SchemaFor[stepDecision]()
+	if err != nil {
+		return nil, err
+	}
+
 	state := components.NewFlowState()
 	currentMessage := uMessage
 	maxSteps := 50
 	workflowName := "Entry Workflow"
 
 	for steps := 0; steps < maxSteps; steps++ {
-		schemaFields := []components.SchemaField{
-			{
-				Field:       "tool_name",
-				Description: "Name of the tool to use",
-				Type:        "string",
-				Required:    true,
-			},
-			{
-				Field:       "tool_input",
-				Description: "Input for the selected tool",
-				Type:        "object",
-				Required:    true,
-			},
-			{
-				Field:       "isComplete",
-				Description: "Whether the task is complete, must be 'true' or 'false'",
-				Type:        "boolean",
-				Required:    true,
-			},
-			{
-				Field:       "nextStep",
-				Description: "The next step to take if the task is not complete",
-				Type:        "string",
-				Required:    false,
-			}, {
-				Field:       "thought",
-				Description: "This section is to capture your thoughts on the current task that can carry over to the next",
-				Type:        "string",
-				Required:    false,
-			},
-			{
-				Field:       "workflowName",
-				Description: "Name for the next workflow",
-				Type:        "string",
-				Required:    true,
-			},
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-
 		schema := &components.JSONSchemaBuilder{
-			Fields: schemaFields,
+			Fields: stepFields,
 		}
 
-		result, err := runSingleStep(workflowName, client, sysMessage, currentMessage, schema, variables, tools)
+		result, err := runSingleStep(ctx, workflowName, client, sysMessage, currentMessage, schema, variables, stepConfig(config.planningParams, 0, config.maxCorrections), run, nil, config.toolWorkers, tools)
 		if err != nil {
 			return nil, err
 		}
@@ -68,14 +195,12 @@ func CoTWorkFlow(client *openai.OpenAIClient, sysMessage string, uMessage string
 			return nil, fmt.Errorf("failed to add to state: %v", err)
 		}
 
-		isComplete, ok := result["isComplete"].(bool)
-		if ok && isComplete {
-			break
-		}
-
-		lastResult, err := state.GetLast()
+		decision, err := components.Decode[stepDecision](result)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get last state: %v", err)
+			return nil, fmt.Errorf("failed to read step decision: %w", err)
+		}
+		if decision.IsComplete {
+			break
 		}
 
 		allSteps, err := state.Get()
@@ -83,40 +208,46 @@ func CoTWorkFlow(client *openai.OpenAIClient, sysMessage string, uMessage string
 			return nil, fmt.Errorf("failed to get state history: %v", err)
 		}
 
-		variables["previous_result"] = lastResult
-		variables["workflow_history"] = allSteps
-
-		nextQuestion, ok := result["nextQuestion"].(string)
-		if !ok || nextQuestion == "" {
-			break
-		}
+		// Only what the tools returned is external content; the decisions
+		// are the flow's own and are not checked for injection
+		decisions, toolOutputs := splitToolOutputs(allSteps)
+		variables["previous_result"] = decisions[len(decisions)-1]
+		variables["workflow_history"] = decisions
+		variables["tool_outputs"] = toolOutputs
 
-		nextWorkflowName, ok := result["workflowName"].(string)
-		if !ok || nextWorkflowName == "" {
+		if decision.NextStep == "" || decision.WorkflowName == "" {
 			break
 		}
 
-		currentMessage = nextQuestion
-		workflowName = nextWorkflowName
+		currentMessage = decision.NextStep
+		workflowName = decision.WorkflowName
 	}
 
 	allResults, err := state.Get()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get final state: %v", err)
 	}
+	decisions, toolOutputs := splitToolOutputs(allResults)
 
 	finalSysMessage := `You are a helpful analysis AI that can take all of the data gathered and provide accurate responses.\n Ensure that you are not returning schema definiton.`
 	finalUserMessage := `Please finish your analysis and respond with the properly formatted JSON object from the provided schema.
-Please use this context to complete the analysis: {{context}}`
+Please use this context to complete the analysis: {{context}}
+These are the outputs of the tools that were run: {{tool_outputs}}`
 	finalStepResult, err := runSingleStep(
+		ctx,
 		"Exit Workflow",
-		client,
+		config.finalClient,
 		finalSysMessage,
 		finalUserMessage,
 		&components.JSONSchemaBuilder{Fields: fields},
 		map[string]interface{}{
-			"context": allResults,
+			"context":      decisions,
+			"tool_outputs": toolOutputs,
 		},
+		stepConfig(config.finalParams, config.finalContinuations, config.maxCorrections),
+		run,
+		config.finalStream,
+		0,
 	)
 	if err != nil {
 		return nil, err
@@ -126,11 +257,16 @@ Please use this context to complete the analysis: {{context}}`
 		"steps":        allResults,
 		"final_output": finalStepResult,
 		"step_count":   len(allResults),
+		"run_id":       run.ID,
 	}, nil
 }
 
-func runSingleStep(workflowName string, client *openai.OpenAIClient, sysMessage string, uMessage string, schema *components.JSONSchemaBuilder, variables map[string]interface{}, tools ...*components.ToolList) (map[string]interface{}, error) {
+func runSingleStep(ctx context.Context, workflowName string, client components.LLMClient, sysMessage string, uMessage string, schema *components.JSONSchemaBuilder, variables map[string]interface{}, config components.WorkflowConfig, run *components.RunRecord, onEvent func(components.StreamEvent), toolWorkers int, tools ...*components.ToolList) (map[string]interface{}, error) {
+	logger := &components.Logger{LogFile: "workflow.log"}
 	parser := components.NewJSONParser(schema.Fields)
+	parser.OnRepair = func(repairs []string) {
+		logger.LogItem(workflowName, "Repaired output: "+strings.Join(repairs, ", "))
+	}
 
 	var toolList *components.ToolList
 
@@ -144,6 +280,10 @@ func runSingleStep(workflowName string, client *openai.OpenAIClient, sysMessage
 		UserMessage:   uMessage,
 		Variables:     variables,
 		Tools:         toolList,
+		// Steps share one variables map, so not every step uses every value
+		AllowUnusedVariables: true,
+		// Tool output is the only external content in a step's variables
+		UntrustedVariables: []string{"tool_outputs"},
 		OutputFormat: components.OutputFormat{
 			Type:        "json",
 			Schema:      schema.Build(),
@@ -155,7 +295,10 @@ func runSingleStep(workflowName string, client *openai.OpenAIClient, sysMessage
 		prompt.Tools = tools[0]
 	}
 
-	prompt.FormatPrompt()
+	prompt, err := prompt.Render()
+	if err != nil {
+		return nil, fmt.Errorf("prompt rendering failed: %w", err)
+	}
 	if prompt.Tools != nil {
 		prompt.AddTools()
 	}
@@ -168,22 +311,22 @@ func runSingleStep(workflowName string, client *openai.OpenAIClient, sysMessage
 		components.WorkFlowDo,
 		client,
 		parser,
-		components.WorkflowConfig{
-			MaxRetries: 3,
-			Timeout:    time.Second * 30,
-		},
+		config,
 		prompt,
 		nil,
-		&components.Logger{LogFile: "workflow.log"},
+		logger,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("workflow creation failed: %v", err)
 	}
+	workflow.OnStreamEvent = onEvent
 
-	result, err := workflow.Run(context.Background())
+	result, err := workflow.Run(ctx)
+	run.AddRunUsage(workflow.LastRun)
+	run.AddAttempts(workflow.LastRun.Attempts)
 	if err != nil {
-		return nil, fmt.Errorf("workflow execution failed: %v", err)
+		return nil, fmt.Errorf("workflow execution failed: %w", err)
 	}
 
 	resultMap, ok := result.(map[string]interface{})
@@ -191,24 +334,107 @@ func runSingleStep(workflowName string, client *openai.OpenAIClient, sysMessage
 		return nil, fmt.Errorf("unexpected result type")
 	}
 	if tools != nil {
-		if toolName, ok := resultMap["tool_name"].(string); ok {
-			if tool, exists := toolList.Tools[toolName]; exists {
-
-				toolInput, err := json.Marshal(resultMap["tool_input"])
-				if err != nil {
-					return nil, fmt.Errorf("failed to marshal tool input: %v", err)
-				}
-
-				tool.Inputs = string(toolInput)
-				fmt.Printf("Tool Input: %v\n", tool.Inputs)
-				toolResult, err := tool.Run()
-				if err != nil {
-					return nil, fmt.Errorf("tool execution failed: %w", err)
-				}
-				resultMap["tool_output"] = toolResult
-			}
+		if err := runStepTools(ctx, resultMap, toolList, toolWorkers, workflowName, logger); err != nil {
+			return nil, err
 		}
 	}
 
 	return resultMap, nil
 }
+
+// runStepTools runs the tools a step asked for and stores what happened in
+// resultMap. A single tool_name call keeps its output under tool_output;
+// calls listed under tool_calls run in parallel and their results are
+// stored in call order under tool_results. A call that fails or times out
+// gets its error, under tool_error, so the next step can react; only
+// cancelling ctx ends the flow.
+func runStepTools(ctx context.Context, resultMap map[string]interface{}, toolList *components.ToolList, workers int, workflowName string, logger *components.Logger) error {
+	var calls []components.ToolCall
+	rawCalls, multiple := resultMap["tool_calls"].([]interface{})
+	multiple = multiple && len(rawCalls) > 0
+	if multiple {
+		for i, raw := range rawCalls {
+			entry, _ := raw.(map[string]interface{})
+			call := components.ToolCall{ID: fmt.Sprintf("call_%d", i+1)}
+			if id, ok := entry["id"].(string); ok && id != "" {
+				call.ID = id
+			}
+			call.Name, _ = entry["tool_name"].(string)
+			arguments, err := json.Marshal(entry["tool_input"])
+			if err != nil {
+				return fmt.Errorf("tool call %s: %w", call.ID, err)
+			}
+			call.Arguments = string(arguments)
+			calls = append(calls, call)
+		}
+	} else if toolName, ok := resultMap["tool_name"].(string); ok && toolName != "" {
+		arguments, err := json.Marshal(resultMap["tool_input"])
+		if err != nil {
+			return fmt.Errorf("tool %s: %w", toolName, err)
+		}
+		calls = append(calls, components.ToolCall{ID: "call_1", Name: toolName, Arguments: string(arguments)})
+	}
+	if len(calls) == 0 {
+		return nil
+	}
+
+	for _, call := range calls {
+		logger.LogItem(workflowName, fmt.Sprintf("Running tool %s with input %s", call.Name, call.Arguments))
+	}
+	results := toolList.RunCalls(ctx, calls, workers)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			logger.LogItem(workflowName, fmt.Sprintf("Tool call %s (%s) failed: %v", result.ID, result.Name, result.Err))
+		}
+	}
+
+	if multiple {
+		resultMap["tool_results"] = results
+	} else if results[0].Err != nil {
+		resultMap["tool_error"] = results[0].Error
+	} else {
+		resultMap["tool_output"] = results[0].Output
+	}
+	return nil
+}
+
+// splitToolOutputs separates the flow's own step decisions from what their
+// tools returned. The decisions come back without tool_output, tool_error
+// or tool_results; the outputs are listed in step order, each tagged with
+// its 1-based step.
+func splitToolOutputs(steps []interface{}) ([]interface{}, []interface{}) {
+	decisions := make([]interface{}, 0, len(steps))
+	outputs := []interface{}{}
+	for i, step := range steps {
+		result, ok := step.(map[string]interface{})
+		if !ok {
+			decisions = append(decisions, step)
+			continue
+		}
+		decision := make(map[string]interface{}, len(result))
+		for key, value := range result {
+			switch key {
+			case "tool_output", "tool_error", "tool_results":
+			default:
+				decision[key] = value
+			}
+		}
+		decisions = append(decisions, decision)
+
+		if results, ok := result["tool_results"].([]components.ToolResult); ok {
+			for _, r := range results {
+				outputs = append(outputs, map[string]interface{}{"step": i + 1, "id": r.ID, "tool_name": r.Name, "tool_output": r.Output, "tool_error": r.Error})
+			}
+			continue
+		}
+		output, hasOutput := result["tool_output"]
+		toolError, hasError := result["tool_error"]
+		if hasOutput || hasError {
+			outputs = append(outputs, map[string]interface{}{"step": i + 1, "tool_name": result["tool_name"], "tool_output": output, "tool_error": toolError})
+		}
+	}
+	return decisions, outputs
+}
@@ -0,0 +1,244 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"goflow/pkg/components"
+	"goflow/pkg/llms/llmtest"
+	"goflow/pkg/llms/openai"
+)
+
+// newTestClient returns an OpenAI client talking to a fake server that
+// answers every request with reply.
+func newTestClient(t *testing.T, reply string) (*openai.OpenAIClient, *llmtest.Server) {
+	t.Helper()
+	server := llmtest.NewServer(llmtest.OpenAIVendor{})
+	t.Cleanup(server.Close)
+	server.Reply(llmtest.Reply{Content: reply})
+	client, err := openai.NewOpenAIClient(components.ClientConfig{Model: "gpt-4o", APIKey: "test", BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+var finalFields = []components.SchemaField{{Field: "analysis", Type: "string"}}
+
+func TestCoTWorkFlowCancellationReachesTools(t *testing.T) {
+	client, _ := newTestClient(t, `{"tool_name": "block", "tool_input": {}, "isComplete": false, "workflowName": "next"}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	toolErr := make(chan error, 1)
+	tools := &components.ToolList{Tools: map[string]components.Tool{
+		"block": {Name: "block", HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+			cancel()
+			<-ctx.Done()
+			toolErr <- ctx.Err()
+			return nil, ctx.Err()
+		}},
+	}}
+
+	_, err := CoTWorkFlow(ctx, client, "system", "Look it up", finalFields, map[string]interface{}{}, tools)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("CoTWorkFlow error = %v, want context.Canceled", err)
+	}
+	if got := <-toolErr; !errors.Is(got, context.Canceled) {
+		t.Errorf("tool context error = %v, want context.Canceled", got)
+	}
+}
+
+func TestSplitToolOutputs(t *testing.T) {
+	steps := []interface{}{
+		map[string]interface{}{"tool_name": "whois", "thought": "use whois first", "tool_output": "Registrar: Example"},
+		map[string]interface{}{"tool_calls": []interface{}{}, "tool_results": []components.ToolResult{
+			{ID: "a", Name: "whois", Output: "one"},
+			{ID: "b", Name: "whois", Error: "tool whois timed out"},
+		}},
+		map[string]interface{}{"isComplete": true},
+	}
+	decisions, outputs := splitToolOutputs(steps)
+
+	wantDecisions := []interface{}{
+		map[string]interface{}{"tool_name": "whois", "thought": "use whois first"},
+		map[string]interface{}{"tool_calls": []interface{}{}},
+		map[string]interface{}{"isComplete": true},
+	}
+	if !reflect.DeepEqual(decisions, wantDecisions) {
+		t.Errorf("decisions = %v, want %v", decisions, wantDecisions)
+	}
+	wantOutputs := []interface{}{
+		map[string]interface{}{"step": 1, "tool_name": "whois", "tool_output": "Registrar: Example", "tool_error": nil},
+		map[string]interface{}{"step": 2, "id": "a", "tool_name": "whois", "tool_output": "one", "tool_error": ""},
+		map[string]interface{}{"step": 2, "id": "b", "tool_name": "whois", "tool_output": nil, "tool_error": "tool whois timed out"},
+	}
+	if !reflect.DeepEqual(outputs, wantOutputs) {
+		t.Errorf("outputs = %v, want %v", outputs, wantOutputs)
+	}
+	if _, ok := steps[0].(map[string]interface{})["tool_output"]; !ok {
+		t.Error("splitToolOutputs modified the flow state")
+	}
+}
+
+func TestRunStepToolsRecordsErrors(t *testing.T) {
+	tools := &components.ToolList{Tools: map[string]components.Tool{
+		"fail": {Name: "fail", HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+			return nil, errors.New("boom")
+		}},
+		"echo": {Name: "echo", HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+			return inputs.(map[string]interface{})["value"], nil
+		}},
+	}}
+	logger := &components.Logger{}
+
+	single := map[string]interface{}{"tool_name": "fail", "tool_input": map[string]interface{}{}}
+	if err := runStepTools(context.Background(), single, tools, 0, "test", logger); err != nil {
+		t.Fatalf("single call: %v", err)
+	}
+	if single["tool_error"] != "error running tool: boom" || single["tool_output"] != nil {
+		t.Errorf("single call result = %v", single)
+	}
+
+	multiple := map[string]interface{}{"tool_calls": []interface{}{
+		map[string]interface{}{"id": "one", "tool_name": "echo", "tool_input": map[string]interface{}{"value": "x"}},
+		map[string]interface{}{"tool_name": "fail", "tool_input": map[string]interface{}{}},
+	}}
+	if err := runStepTools(context.Background(), multiple, tools, 0, "test", logger); err != nil {
+		t.Fatalf("parallel calls: %v", err)
+	}
+	results := multiple["tool_results"].([]components.ToolResult)
+	if len(results) != 2 || results[0].ID != "one" || results[0].Output != "x" || results[1].ID != "call_2" || results[1].Error == "" {
+		t.Errorf("parallel results = %+v", results)
+	}
+}
+
+func TestCoTWorkFlowRecordsStepAttempts(t *testing.T) {
+	client, _ := newTestClient(t, `{"isComplete": true, "workflowName": "done", "analysis": "ok"}`)
+	recorder := components.NewExperimentRecorder()
+	experiment := &components.Experiment{
+		Name:     "cot",
+		Variants: []components.PromptVariant{{Name: "a", Weight: 1, Prompt: components.Prompt{SystemMessage: "system"}}},
+		Recorder: recorder,
+	}
+
+	if _, err := CoTWorkFlow(context.Background(), client, "system", "Look it up", finalFields, map[string]interface{}{}, nil, WithExperiment(experiment, "user")); err != nil {
+		t.Fatal(err)
+	}
+	runs := recorder.Runs("cot")
+	if len(runs) != 1 {
+		t.Fatalf("recorded %d runs, want 1", len(runs))
+	}
+	// One tool-selection step and the final step
+	if got := len(runs[0].Attempts); got != 2 {
+		t.Errorf("run has %d attempts, want 2", got)
+	}
+	if report := recorder.Report("cot"); len(report) != 1 || report[0].MeanAttempts != 2 {
+		t.Errorf("report = %+v, want MeanAttempts 2", report)
+	}
+}
+
+// fakeClient replays canned responses in order and records every prompt
+// it is sent. Once the responses run out it answers with the last one.
+type fakeClient struct {
+	mu        sync.Mutex
+	responses []string
+	prompts   []components.Prompt
+}
+
+func (c *fakeClient) Generate(ctx context.Context, prompt components.Prompt) (string, error) {
+	completion, err := c.Complete(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return completion.Choices[0].Content, nil
+}
+
+func (c *fakeClient) Complete(ctx context.Context, prompt components.Prompt) (*components.Completion, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prompts = append(c.prompts, prompt)
+	response := c.responses[0]
+	if len(c.responses) > 1 {
+		c.responses = c.responses[1:]
+	}
+	return &components.Completion{Choices: []components.Choice{{Content: response, FinishReason: components.FinishReasonStop}}}, nil
+}
+
+func (c *fakeClient) GetModelInfo() components.ModelInfo {
+	return components.ModelInfo{Provider: "fake", Model: "fake-model"}
+}
+
+func (c *fakeClient) ValidateResponse(response string) error {
+	return nil
+}
+
+func TestRunCoTFollowsNextStep(t *testing.T) {
+	client := &fakeClient{responses: []string{
+		`{"tool_name": "lookup", "tool_input": {"domain": "example.com"}, "isComplete": false, "nextStep": "Check the registrar", "workflowName": "Registrar"}`,
+		`{"tool_calls": [{"id": "a", "tool_name": "lookup", "tool_input": {"domain": "example.org"}}, {"id": "b", "tool_name": "lookup", "tool_input": {"domain": "example.net"}}], "isComplete": false, "nextStep": "Summarise", "workflowName": "Summary"}`,
+		`{"isComplete": true, "workflowName": "Done"}`,
+		`{"analysis": "all registered"}`,
+	}}
+	tools := &components.ToolList{Tools: map[string]components.Tool{
+		"lookup": {Name: "lookup", HandlerFunc: func(ctx context.Context, inputs interface{}) (interface{}, error) {
+			return "registered: " + inputs.(map[string]interface{})["domain"].(string), nil
+		}},
+	}}
+	config := &cotConfig{finalClient: client, maxCorrections: defaultCorrections}
+
+	result, err := runCoT(context.Background(), client, "system", "Look up example.com", finalFields, map[string]interface{}{}, tools, config, components.NewRunRecord("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := result.(map[string]interface{})
+	if got := output["step_count"]; got != 3 {
+		t.Errorf("step_count = %v, want 3", got)
+	}
+	if got := output["final_output"].(map[string]interface{})["analysis"]; got != "all registered" {
+		t.Errorf("final analysis = %v", got)
+	}
+
+	if len(client.prompts) != 4 {
+		t.Fatalf("client got %d prompts, want 4", len(client.prompts))
+	}
+	for i, want := range []string{"Look up example.com", "Check the registrar", "Summarise"} {
+		if !strings.Contains(client.prompts[i].UserMessage, want) {
+			t.Errorf("step %d prompt does not ask %q:\n%s", i+1, want, client.prompts[i].UserMessage)
+		}
+	}
+	final := client.prompts[3].UserMessage
+	for _, want := range []string{"registered: example.com", "registered: example.org", "registered: example.net"} {
+		if !strings.Contains(final, want) {
+			t.Errorf("final prompt is missing tool output %q", want)
+		}
+	}
+}
+
+func TestRunCoTAppliesStepParams(t *testing.T) {
+	client := &fakeClient{responses: []string{
+		`{"isComplete": true, "workflowName": "Done"}`,
+		`{"analysis": "nothing to do"}`,
+	}}
+	config := &cotConfig{finalClient: client, maxCorrections: defaultCorrections}
+	WithPlanningParams(components.GenerationParams{Temperature: components.Float(0.1), Seed: components.Int(7)})(config)
+	WithFinalParams(components.GenerationParams{Temperature: components.Float(0.9), MaxTokens: components.Int(2000)})(config)
+
+	if _, err := runCoT(context.Background(), client, "system", "Look up example.com", finalFields, map[string]interface{}{}, &components.ToolList{}, config, components.NewRunRecord("test")); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.prompts) != 2 {
+		t.Fatalf("client got %d prompts, want 2", len(client.prompts))
+	}
+	planning, final := client.prompts[0].Params, client.prompts[1].Params
+	if planning.Temperature == nil || *planning.Temperature != 0.1 || planning.Seed == nil || *planning.Seed != 7 || planning.MaxTokens != nil {
+		t.Errorf("planning params = %+v, want temperature 0.1 and seed 7 only", planning)
+	}
+	if final.Temperature == nil || *final.Temperature != 0.9 || final.MaxTokens == nil || *final.MaxTokens != 2000 || final.Seed != nil {
+		t.Errorf("final params = %+v, want temperature 0.9 and 2000 max tokens only", final)
+	}
+}
@@ -33,7 +33,10 @@ func BasicFlow(client *openai.OpenAIClient, sysMessage string, uMessage string,
         },
     }
     // Format Prompt to include ouput schema 
-    prompt.FormatPrompt()
+    prompt, err := prompt.Render()
+    if err != nil {
+        return nil, fmt.Errorf("prompt rendering failed: %w", err)
+    }
 
     // 5. Create and run workflow
     workflow, err := components.NewWorkflow(
@@ -91,7 +94,10 @@ func BasicContextFlow(client *openai.OpenAIClient, sysMessage string, uMessage s
         },
     }
     // Format Prompt to include ouput schema 
-    prompt.FormatPrompt()
+    prompt, err := prompt.Render()
+    if err != nil {
+        return nil, fmt.Errorf("prompt rendering failed: %w", err)
+    }
 
     // 5. Create and run workflow
     workflow, err := components.NewWorkflow(
@@ -149,7 +155,10 @@ func BasicToolFlow(client *openai.OpenAIClient, sysMessage string, uMessage stri
         
     }
     // Format Prompt to include ouput schema 
-    prompt.FormatPrompt()
+    prompt, err := prompt.Render()
+    if err != nil {
+        return nil, fmt.Errorf("prompt rendering failed: %w", err)
+    }
     prompt.AddTools()
 
     // 5. Create and run workflow
@@ -191,7 +200,7 @@ func BasicToolFlow(client *openai.OpenAIClient, sysMessage string, uMessage stri
 
         selectedTool.Inputs = resultMap["tool_input"]
 
-        toolResult, err := selectedTool.Run()
+        toolResult, err := selectedTool.Run(ctx)
         if err != nil {
             return nil, fmt.Errorf("tool execution failed: %w", err)
         }
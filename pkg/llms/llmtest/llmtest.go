@@ -0,0 +1,358 @@
+// Package llmtest is a conformance suite for components.LLMClient
+// implementations. It runs the client against a fake server speaking the
+// vendor's wire format and checks that it behaves the way pkg/flows expects:
+//
+//	func TestConformance(t *testing.T) {
+//		llmtest.Run(t, llmtest.OpenAIVendor{}, func(baseURL string) (components.LLMClient, error) {
+//			return openai.NewOpenAIClient(components.ClientConfig{
+//				Model:   "gpt-4o",
+//				APIKey:  "test",
+//				BaseURL: baseURL,
+//			})
+//		})
+//	}
+//
+// OpenAIVendor is the only wire format implemented so far. Clients for other
+// APIs need their own Vendor before they can run the suite.
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"goflow/pkg/components"
+)
+
+// Factory builds the client under test, pointed at the fake server.
+type Factory func(baseURL string) (components.LLMClient, error)
+
+// Run executes every conformance check as a subtest of t.
+func Run(t *testing.T, vendor Vendor, newClient Factory) {
+	checks := []struct {
+		name string
+		run  func(t *testing.T, server *Server, client components.LLMClient)
+	}{
+		{"PlainGeneration", testPlainGeneration},
+		{"JSONOutput", testJSONOutput},
+		{"SystemPrompt", testSystemPrompt},
+		{"ToolCalling", testToolCalling},
+		{"Streaming", testStreaming},
+		{"ErrorMapping", testErrorMapping},
+		{"ContextCancellation", testContextCancellation},
+		{"Usage", testUsage},
+	}
+
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			server := NewServer(vendor)
+			defer server.Close()
+
+			client, err := newClient(server.URL)
+			if err != nil {
+				t.Fatalf("creating client: %v", err)
+			}
+			check.run(t, server, client)
+		})
+	}
+}
+
+func testPrompt() components.Prompt {
+	return components.Prompt{
+		SystemMessage: "You are a geography assistant.",
+		UserMessage:   "What is the capital of France?",
+	}
+}
+
+func testPlainGeneration(t *testing.T, server *Server, client components.LLMClient) {
+	server.Reply(Reply{Content: "Paris."})
+
+	response, err := client.Generate(context.Background(), testPrompt())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if response != "Paris." {
+		t.Errorf("Generate = %q, want %q", response, "Paris.")
+	}
+
+	completion, err := client.Complete(context.Background(), testPrompt())
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if len(completion.Choices) != 1 {
+		t.Fatalf("Complete returned %d choices, want 1", len(completion.Choices))
+	}
+	if got := completion.Choices[0].FinishReason; got != components.FinishReasonStop {
+		t.Errorf("FinishReason = %q, want %q", got, components.FinishReasonStop)
+	}
+}
+
+func testJSONOutput(t *testing.T, server *Server, client components.LLMClient) {
+	fields := []components.SchemaField{
+		{Field: "city", Description: "The capital city", Type: "string", Required: true},
+	}
+	schema := &components.JSONSchemaBuilder{Fields: fields}
+	prompt := testPrompt()
+	prompt.OutputFormat = components.OutputFormat{
+		Type:        "json",
+		Schema:      schema.Build(),
+		Description: "Return a JSON object with the specified fields.",
+	}
+	prompt, err := prompt.Render()
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	server.Reply(Reply{Content: `{"city": "Paris"}`})
+
+	workflow, err := components.NewWorkflow(
+		"llmtest",
+		components.WorkFlowDo,
+		client,
+		components.NewJSONParser(fields),
+		components.WorkflowConfig{},
+		prompt,
+		nil,
+		&components.Logger{},
+	)
+	if err != nil {
+		t.Fatalf("NewWorkflow: %v", err)
+	}
+	result, err := workflow.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if city := result.(map[string]interface{})["city"]; city != "Paris" {
+		t.Errorf("city = %v, want Paris", city)
+	}
+
+	system := systemText(server.LastRequest())
+	if !strings.Contains(system, `"city"`) {
+		t.Errorf("system instructions do not include the schema: %q", system)
+	}
+}
+
+func testSystemPrompt(t *testing.T, server *Server, client components.LLMClient) {
+	server.Reply(Reply{Content: "ok"})
+
+	prompt := testPrompt()
+	prompt.Turns = []components.Message{
+		{Role: components.RoleAssistant, Content: "Paris."},
+		{Role: components.RoleUser, Content: "And of Italy?"},
+	}
+	if _, err := client.Generate(context.Background(), prompt); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	req := server.LastRequest()
+	if !strings.Contains(systemText(req), prompt.SystemMessage) {
+		t.Errorf("system message %q not sent, got %+v", prompt.SystemMessage, req.Messages)
+	}
+
+	var rest []components.Message
+	for _, msg := range req.Messages {
+		if msg.Role != components.RoleSystem {
+			rest = append(rest, msg)
+		}
+	}
+	want := []components.Message{
+		{Role: components.RoleUser, Content: prompt.UserMessage},
+		prompt.Turns[0],
+		prompt.Turns[1],
+	}
+	if len(rest) != len(want) {
+		t.Fatalf("sent %d conversation turns, want %d: %+v", len(rest), len(want), rest)
+	}
+	for i := range want {
+		if rest[i].Role != want[i].Role || !strings.Contains(rest[i].Content, want[i].Content) {
+			t.Errorf("turn %d = %+v, want %+v", i, rest[i], want[i])
+		}
+	}
+}
+
+func testToolCalling(t *testing.T, server *Server, client components.LLMClient) {
+	tools := &components.ToolList{Tools: map[string]components.Tool{
+		"whois": {
+			Name:        "whois",
+			Description: "Performs a whois lookup for a domain.",
+			Schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"domain": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	}}
+	call := components.ToolCall{ID: "call_1", Name: "whois", Arguments: `{"domain":"example.com"}`}
+	server.Reply(Reply{ToolCalls: []components.ToolCall{call}})
+
+	prompt := testPrompt()
+	prompt.Tools = tools
+	prompt.ToolChoice = components.ToolChoiceAuto
+	completion, err := client.Complete(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	req := server.LastRequest()
+	if len(req.Tools) != 1 || req.Tools[0].Name != "whois" || req.Tools[0].Description == "" {
+		t.Errorf("tools sent = %+v, want the whois definition", req.Tools)
+	} else if req.Tools[0].Schema["type"] != "object" {
+		t.Errorf("tool schema = %v, want an object schema", req.Tools[0].Schema)
+	}
+
+	choice := completion.Choices[0]
+	if choice.FinishReason != components.FinishReasonToolCalls {
+		t.Errorf("FinishReason = %q, want %q", choice.FinishReason, components.FinishReasonToolCalls)
+	}
+	if len(choice.ToolCalls) != 1 || choice.ToolCalls[0] != call {
+		t.Fatalf("ToolCalls = %+v, want [%+v]", choice.ToolCalls, call)
+	}
+
+	// The tool result must round-trip with its call ID.
+	server.Reply(Reply{Content: "example.com is registered."})
+	prompt.Turns = []components.Message{
+		{Role: components.RoleAssistant, ToolCalls: choice.ToolCalls},
+		{Role: components.RoleTool, ToolCallID: call.ID, Content: "Registrar: Example Inc."},
+	}
+	if _, err := client.Generate(context.Background(), prompt); err != nil {
+		t.Fatalf("Generate with tool result: %v", err)
+	}
+
+	var sawCall, sawResult bool
+	for _, msg := range server.LastRequest().Messages {
+		if msg.Role == components.RoleAssistant && len(msg.ToolCalls) == 1 && msg.ToolCalls[0].ID == call.ID {
+			sawCall = true
+		}
+		if msg.Role == components.RoleTool && msg.ToolCallID == call.ID && msg.Content == "Registrar: Example Inc." {
+			sawResult = true
+		}
+	}
+	if !sawCall || !sawResult {
+		t.Errorf("tool call round-trip missing (call %v, result %v): %+v", sawCall, sawResult, server.LastRequest().Messages)
+	}
+}
+
+func testStreaming(t *testing.T, server *Server, client components.LLMClient) {
+	streamer, ok := client.(components.StreamingClient)
+	if !ok {
+		t.Skip("client does not implement components.StreamingClient")
+	}
+
+	usage := components.Usage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13}
+	server.Reply(Reply{Content: "The capital is Paris.", Usage: usage}, "The capital", " is", " Paris.")
+
+	var deltas []string
+	completion, err := streamer.Stream(context.Background(), testPrompt(), func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if !server.LastRequest().Stream {
+		t.Error("request was not sent as a stream")
+	}
+	if len(deltas) < 2 {
+		t.Errorf("received %d deltas, want the response delivered incrementally", len(deltas))
+	}
+	if got := strings.Join(deltas, ""); got != "The capital is Paris." {
+		t.Errorf("joined deltas = %q", got)
+	}
+	if got := completion.Choices[0].Content; got != "The capital is Paris." {
+		t.Errorf("completion content = %q", got)
+	}
+	if completion.Usage != usage {
+		t.Errorf("stream usage = %+v, want %+v", completion.Usage, usage)
+	}
+
+	// An error from the callback aborts the stream and is returned as is.
+	stop := errors.New("stop")
+	server.Reply(Reply{Content: "The capital is Paris."}, "The capital", " is", " Paris.")
+	if _, err := streamer.Stream(context.Background(), testPrompt(), func(string) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("Stream with failing callback = %v, want %v", err, stop)
+	}
+}
+
+func testErrorMapping(t *testing.T, server *Server, client components.LLMClient) {
+	kinds := []error{
+		components.ErrRateLimited,
+		components.ErrAuth,
+		components.ErrContextLengthExceeded,
+		components.ErrContentFiltered,
+		components.ErrTimeout,
+		components.ErrProviderUnavailable,
+		components.ErrInvalidRequest,
+	}
+	for _, kind := range kinds {
+		failure := Failure{Kind: kind}
+		if kind == components.ErrRateLimited {
+			failure.RetryAfter = 1500 * time.Millisecond
+		}
+		server.Fail(failure)
+
+		_, err := client.Generate(context.Background(), testPrompt())
+		if !errors.Is(err, kind) {
+			t.Errorf("failure %q mapped to %v", kind, err)
+			continue
+		}
+		var llmErr *components.LLMError
+		if !errors.As(err, &llmErr) {
+			t.Errorf("failure %q: error %T is not a *components.LLMError", kind, err)
+		}
+		if failure.RetryAfter > 0 {
+			if wait, ok := components.RetryAfter(err); !ok || wait != failure.RetryAfter {
+				t.Errorf("RetryAfter = %v, %v, want %v", wait, ok, failure.RetryAfter)
+			}
+		}
+	}
+}
+
+func testContextCancellation(t *testing.T, server *Server, client components.LLMClient) {
+	server.Hang()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.Generate(ctx, testPrompt())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Generate after cancel = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Generate took %v to notice cancellation", elapsed)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Generate(ctx, testPrompt())
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, components.ErrTimeout) {
+		t.Errorf("Generate past deadline = %v, want context.DeadlineExceeded mapped to ErrTimeout", err)
+	}
+}
+
+func testUsage(t *testing.T, server *Server, client components.LLMClient) {
+	usage := components.Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}
+	server.Reply(Reply{Content: "Paris.", Usage: usage})
+
+	completion, err := client.Complete(context.Background(), testPrompt())
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if completion.Usage != usage {
+		t.Errorf("Usage = %+v, want %+v", completion.Usage, usage)
+	}
+}
+
+// systemText joins every system instruction in the request.
+func systemText(req Request) string {
+	var parts []string
+	for _, msg := range req.Messages {
+		if msg.Role == components.RoleSystem {
+			parts = append(parts, msg.Content)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,252 @@
+package llmtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goflow/pkg/components"
+)
+
+// OpenAIVendor speaks the OpenAI chat completions wire format.
+type OpenAIVendor struct{}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    json.RawMessage  `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+func (OpenAIVendor) DecodeRequest(r *http.Request) (Request, error) {
+	var body struct {
+		Model    string          `json:"model"`
+		Messages []openAIMessage `json:"messages"`
+		Stream   bool            `json:"stream"`
+		Tools    []struct {
+			Function struct {
+				Name        string                 `json:"name"`
+				Description string                 `json:"description"`
+				Parameters  map[string]interface{} `json:"parameters"`
+			} `json:"function"`
+		} `json:"tools"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return Request{}, fmt.Errorf("decoding request: %w", err)
+	}
+
+	req := Request{Model: body.Model, Stream: body.Stream}
+	for _, msg := range body.Messages {
+		content, err := openAIContent(msg.Content)
+		if err != nil {
+			return Request{}, err
+		}
+		role := msg.Role
+		if role == "developer" {
+			role = components.RoleSystem
+		}
+		decoded := components.Message{Role: role, Content: content, ToolCallID: msg.ToolCallID}
+		for _, call := range msg.ToolCalls {
+			decoded.ToolCalls = append(decoded.ToolCalls, components.ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
+		req.Messages = append(req.Messages, decoded)
+	}
+	for _, tool := range body.Tools {
+		req.Tools = append(req.Tools, ToolSpec{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Schema:      tool.Function.Parameters,
+		})
+	}
+	return req, nil
+}
+
+// openAIContent flattens message content, which may be a string or a list
+// of text parts.
+func openAIContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("decoding message content: %w", err)
+	}
+	for _, part := range parts {
+		text += part.Text
+	}
+	return text, nil
+}
+
+func openAIUsage(usage components.Usage) map[string]interface{} {
+	return map[string]interface{}{
+		"prompt_tokens":     usage.PromptTokens,
+		"completion_tokens": usage.CompletionTokens,
+		"total_tokens":      usage.TotalTokens,
+		"completion_tokens_details": map[string]interface{}{
+			"reasoning_tokens": usage.ReasoningTokens,
+		},
+	}
+}
+
+func openAIFinishReason(reply Reply) string {
+	switch {
+	case reply.FinishReason != "":
+		return reply.FinishReason
+	case len(reply.ToolCalls) > 0:
+		return components.FinishReasonToolCalls
+	default:
+		return components.FinishReasonStop
+	}
+}
+
+func openAIToolCalls(calls []components.ToolCall, withIndex bool) []openAIToolCall {
+	var result []openAIToolCall
+	for i, call := range calls {
+		encoded := openAIToolCall{ID: call.ID, Type: "function"}
+		if withIndex {
+			index := i
+			encoded.Index = &index
+		}
+		encoded.Function.Name = call.Name
+		encoded.Function.Arguments = call.Arguments
+		result = append(result, encoded)
+	}
+	return result
+}
+
+func (OpenAIVendor) WriteResponse(w http.ResponseWriter, req Request, reply Reply) {
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": reply.Content,
+	}
+	if len(reply.ToolCalls) > 0 {
+		message["tool_calls"] = openAIToolCalls(reply.ToolCalls, false)
+		if reply.Content == "" {
+			message["content"] = nil
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      "chatcmpl-llmtest",
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+		"choices": []interface{}{
+			map[string]interface{}{
+				"index":         0,
+				"message":       message,
+				"finish_reason": openAIFinishReason(reply),
+				"logprobs":      nil,
+			},
+		},
+		"usage": openAIUsage(reply.Usage),
+	})
+}
+
+func (OpenAIVendor) WriteStream(w http.ResponseWriter, req Request, reply Reply, chunks []string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+
+	send := func(choices []interface{}, usage map[string]interface{}) {
+		chunk := map[string]interface{}{
+			"id":      "chatcmpl-llmtest",
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   req.Model,
+			"choices": choices,
+		}
+		if usage != nil {
+			chunk["usage"] = usage
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	delta := func(delta map[string]interface{}, finishReason interface{}) []interface{} {
+		return []interface{}{map[string]interface{}{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}}
+	}
+
+	send(delta(map[string]interface{}{"role": "assistant", "content": ""}, nil), nil)
+	for _, chunk := range chunks {
+		if chunk != "" {
+			send(delta(map[string]interface{}{"content": chunk}, nil), nil)
+		}
+	}
+	if len(reply.ToolCalls) > 0 {
+		send(delta(map[string]interface{}{"tool_calls": openAIToolCalls(reply.ToolCalls, true)}, nil), nil)
+	}
+	send(delta(map[string]interface{}{}, openAIFinishReason(reply)), nil)
+	send([]interface{}{}, openAIUsage(reply.Usage))
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func (OpenAIVendor) WriteError(w http.ResponseWriter, failure Failure) {
+	status, code := http.StatusBadRequest, "invalid_request_error"
+	switch {
+	case errors.Is(failure.Kind, components.ErrRateLimited):
+		status, code = http.StatusTooManyRequests, "rate_limit_exceeded"
+	case errors.Is(failure.Kind, components.ErrAuth):
+		status, code = http.StatusUnauthorized, "invalid_api_key"
+	case errors.Is(failure.Kind, components.ErrContextLengthExceeded):
+		status, code = http.StatusBadRequest, "context_length_exceeded"
+	case errors.Is(failure.Kind, components.ErrContentFiltered):
+		status, code = http.StatusBadRequest, "content_policy_violation"
+	case errors.Is(failure.Kind, components.ErrTimeout):
+		status, code = http.StatusGatewayTimeout, "timeout"
+	case errors.Is(failure.Kind, components.ErrProviderUnavailable):
+		status, code = http.StatusServiceUnavailable, "server_error"
+	}
+
+	message := failure.Message
+	if message == "" {
+		message = fmt.Sprintf("llmtest: %v", failure.Kind)
+	}
+
+	if failure.RetryAfter > 0 {
+		w.Header().Set("Retry-After-Ms", strconv.FormatInt(failure.RetryAfter.Milliseconds(), 10))
+	}
+	// Keep the SDK from retrying so each scripted failure is seen once.
+	w.Header().Set("X-Should-Retry", "false")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    code,
+			"code":    code,
+		},
+	})
+}
@@ -0,0 +1,145 @@
+package llmtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"goflow/pkg/components"
+)
+
+// Vendor mimics a provider's HTTP wire format so the suite can stand in
+// for its API. Each provider package ships one alongside its client.
+type Vendor interface {
+	// DecodeRequest extracts the vendor-neutral view of an outgoing request.
+	DecodeRequest(r *http.Request) (Request, error)
+	// WriteResponse writes reply as a complete, non-streamed response.
+	WriteResponse(w http.ResponseWriter, req Request, reply Reply)
+	// WriteStream writes reply in the vendor's streaming format, sending
+	// the content as the given chunks.
+	WriteStream(w http.ResponseWriter, req Request, reply Reply, chunks []string)
+	// WriteError writes the vendor's response for a failure of the given kind.
+	WriteError(w http.ResponseWriter, failure Failure)
+}
+
+// Request is what the client under test sent, normalised across vendors.
+// Developer and system instructions are both reported as RoleSystem.
+type Request struct {
+	Model    string
+	Messages []components.Message
+	Tools    []ToolSpec
+	Stream   bool
+}
+
+// ToolSpec is a tool definition found in a request.
+type ToolSpec struct {
+	Name        string
+	Description string
+	Schema      map[string]interface{}
+}
+
+// Reply is a scripted model response.
+type Reply struct {
+	Content      string
+	FinishReason string
+	ToolCalls    []components.ToolCall
+	Usage        components.Usage
+}
+
+// Failure is a scripted error response. Kind is one of the components
+// sentinel errors the client is expected to map it back to.
+type Failure struct {
+	Kind       error
+	RetryAfter time.Duration
+	Message    string
+}
+
+// Server is a fake vendor endpoint. It answers every request with the most
+// recently scripted behaviour and records what it received.
+type Server struct {
+	*httptest.Server
+	vendor Vendor
+
+	mu       sync.Mutex
+	respond  func(w http.ResponseWriter, r *http.Request, req Request)
+	requests []Request
+}
+
+// NewServer starts a fake endpoint speaking the vendor's wire format.
+func NewServer(vendor Vendor) *Server {
+	s := &Server{vendor: vendor}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	req, err := s.vendor.DecodeRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	respond := s.respond
+	s.mu.Unlock()
+
+	if respond == nil {
+		http.Error(w, "no response scripted", http.StatusInternalServerError)
+		return
+	}
+	respond(w, r, req)
+}
+
+func (s *Server) script(respond func(w http.ResponseWriter, r *http.Request, req Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.respond = respond
+}
+
+// Reply answers with reply, streamed in chunks when the client asks for a
+// stream.
+func (s *Server) Reply(reply Reply, chunks ...string) {
+	if len(chunks) == 0 {
+		chunks = []string{reply.Content}
+	}
+	s.script(func(w http.ResponseWriter, r *http.Request, req Request) {
+		if req.Stream {
+			s.vendor.WriteStream(w, req, reply, chunks)
+			return
+		}
+		s.vendor.WriteResponse(w, req, reply)
+	})
+}
+
+// Fail answers with the vendor's error response for failure.
+func (s *Server) Fail(failure Failure) {
+	s.script(func(w http.ResponseWriter, r *http.Request, req Request) {
+		s.vendor.WriteError(w, failure)
+	})
+}
+
+// Hang never answers; the request is held until the client gives up.
+func (s *Server) Hang() {
+	s.script(func(w http.ResponseWriter, r *http.Request, req Request) {
+		<-r.Context().Done()
+	})
+}
+
+// Requests returns every request received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the most recent request, or the zero Request.
+func (s *Server) LastRequest() Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return Request{}
+	}
+	return s.requests[len(s.requests)-1]
+}
@@ -0,0 +1,37 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/openai/openai-go"
+	gf "goflow/pkg/components"
+)
+
+// defaultEmbeddingModel is used when ClientConfig.EmbeddingModel is empty.
+const defaultEmbeddingModel = "text-embedding-3-small"
+
+var _ gf.Embedder = (*OpenAIClient)(nil)
+
+// Embed implements gf.Embedder with the embeddings API.
+func (c *OpenAIClient) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	model := c.config.EmbeddingModel
+	if model == "" {
+		model = defaultEmbeddingModel
+	}
+
+	resp, err := c.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input: openai.F[openai.EmbeddingNewParamsInputUnion](openai.EmbeddingNewParamsInputArrayOfStrings(texts)),
+		Model: openai.F(model),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, item := range resp.Data {
+		if int(item.Index) < len(vectors) {
+			vectors[item.Index] = item.Embedding
+		}
+	}
+	return vectors, nil
+}
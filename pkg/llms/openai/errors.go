@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go"
+	gf "goflow/pkg/components"
+)
+
+// mapError translates an openai-go failure into a *gf.LLMError. Context
+// cancellation is returned untouched since it is the caller's decision,
+// not a provider failure.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		code, message := errorDetails(apiErr)
+		return &gf.LLMError{
+			Kind:       classifyAPIError(apiErr.StatusCode, code),
+			Provider:   "openai",
+			StatusCode: apiErr.StatusCode,
+			RetryAfter: retryAfter(apiErr.Response),
+			Message:    message,
+			Err:        err,
+		}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &gf.LLMError{Kind: gf.ErrTimeout, Provider: "openai", Err: err}
+	}
+
+	return &gf.LLMError{Kind: gf.ErrProviderUnavailable, Provider: "openai", Err: err}
+}
+
+// errorDetails extracts the error code and message. The API nests them under
+// an "error" key, which the SDK does not unwrap.
+func errorDetails(apiErr *openai.Error) (string, string) {
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(apiErr.JSON.RawJSON()), &body); err == nil && body.Error.Message != "" {
+		return body.Error.Code, body.Error.Message
+	}
+	return apiErr.Code, apiErr.Message
+}
+
+func classifyAPIError(status int, code string) error {
+	switch code {
+	case "context_length_exceeded":
+		return gf.ErrContextLengthExceeded
+	case "content_filter", "content_policy_violation":
+		return gf.ErrContentFiltered
+	case "invalid_api_key":
+		return gf.ErrAuth
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return gf.ErrAuth
+	case status == http.StatusTooManyRequests:
+		return gf.ErrRateLimited
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return gf.ErrTimeout
+	case status >= 500:
+		return gf.ErrProviderUnavailable
+	default:
+		return gf.ErrInvalidRequest
+	}
+}
+
+// retryAfter reads the back-off hint from retry-after-ms or retry-after,
+// the latter being either seconds or an HTTP date.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	if ms, err := strconv.ParseFloat(resp.Header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	gf "goflow/pkg/components"
+)
+
+// apiError builds the error the SDK returns for a response with the given
+// status, headers and body.
+func apiError(t *testing.T, status int, header http.Header, body string) *openai.Error {
+	t.Helper()
+	apiErr := &openai.Error{
+		StatusCode: status,
+		Request:    httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", nil),
+		Response:   &http.Response{StatusCode: status, Header: header},
+	}
+	if err := apiErr.UnmarshalJSON([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	return apiErr
+}
+
+func TestMapErrorStatus(t *testing.T) {
+	tests := []struct {
+		status  int
+		code    string
+		want    error
+		retries bool
+	}{
+		{status: http.StatusBadRequest, want: gf.ErrInvalidRequest},
+		{status: http.StatusBadRequest, code: "context_length_exceeded", want: gf.ErrContextLengthExceeded},
+		{status: http.StatusBadRequest, code: "content_policy_violation", want: gf.ErrContentFiltered},
+		{status: http.StatusBadRequest, code: "content_filter", want: gf.ErrContentFiltered},
+		{status: http.StatusUnauthorized, code: "invalid_api_key", want: gf.ErrAuth},
+		{status: http.StatusUnauthorized, want: gf.ErrAuth},
+		{status: http.StatusForbidden, want: gf.ErrAuth},
+		{status: http.StatusNotFound, want: gf.ErrInvalidRequest},
+		{status: http.StatusRequestTimeout, want: gf.ErrTimeout, retries: true},
+		{status: http.StatusTooManyRequests, want: gf.ErrRateLimited, retries: true},
+		{status: http.StatusInternalServerError, want: gf.ErrProviderUnavailable, retries: true},
+		{status: http.StatusServiceUnavailable, want: gf.ErrProviderUnavailable, retries: true},
+		{status: http.StatusGatewayTimeout, want: gf.ErrTimeout, retries: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.status, tt.code), func(t *testing.T) {
+			body := fmt.Sprintf(`{"error": {"message": "something failed", "type": "error", "code": %q}}`, tt.code)
+			apiErr := apiError(t, tt.status, http.Header{}, body)
+			err := mapError(fmt.Errorf("sending request: %w", apiErr))
+
+			if !errors.Is(err, tt.want) {
+				t.Errorf("mapError() = %v, want %v", err, tt.want)
+			}
+			for _, other := range []error{gf.ErrRateLimited, gf.ErrContextLengthExceeded, gf.ErrContentFiltered, gf.ErrAuth, gf.ErrTimeout, gf.ErrProviderUnavailable, gf.ErrInvalidRequest} {
+				if other != tt.want && errors.Is(err, other) {
+					t.Errorf("mapError() also matches %v", other)
+				}
+			}
+			if gf.IsRetryable(err) != tt.retries {
+				t.Errorf("IsRetryable() = %v, want %v", !tt.retries, tt.retries)
+			}
+
+			var llmErr *gf.LLMError
+			if !errors.As(err, &llmErr) {
+				t.Fatalf("mapError() = %T, want *LLMError", err)
+			}
+			if llmErr.Provider != "openai" || llmErr.StatusCode != tt.status || llmErr.Message != "something failed" {
+				t.Errorf("LLMError = %+v", llmErr)
+			}
+			var sdkErr *openai.Error
+			if !errors.As(err, &sdkErr) || sdkErr != apiErr {
+				t.Error("the SDK error is not reachable with errors.As")
+			}
+		})
+	}
+}
+
+func TestMapErrorRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "none", header: http.Header{}},
+		{name: "seconds", header: http.Header{"Retry-After": {"2"}}, want: 2 * time.Second},
+		{name: "milliseconds win", header: http.Header{"Retry-After": {"2"}, "Retry-After-Ms": {"250"}}, want: 250 * time.Millisecond},
+		{name: "past date", header: http.Header{"Retry-After": {"Mon, 02 Jan 2006 15:04:05 GMT"}}},
+		{name: "garbage", header: http.Header{"Retry-After": {"soon"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mapError(apiError(t, http.StatusTooManyRequests, tt.header, `{"error": {"message": "slow down"}}`))
+			got, ok := gf.RetryAfter(err)
+			if got != tt.want || ok != (tt.want > 0) {
+				t.Errorf("RetryAfter() = %v, %v; want %v", got, ok, tt.want)
+			}
+		})
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	err := mapError(apiError(t, http.StatusTooManyRequests, http.Header{"Retry-After": {future}}, `{}`))
+	if got, ok := gf.RetryAfter(err); !ok || got <= 50*time.Second || got > time.Minute {
+		t.Errorf("RetryAfter() with an HTTP date = %v, %v; want about a minute", got, ok)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestMapErrorTransport(t *testing.T) {
+	if err := mapError(nil); err != nil {
+		t.Errorf("mapError(nil) = %v", err)
+	}
+
+	canceled := fmt.Errorf("request: %w", context.Canceled)
+	if err := mapError(canceled); err != canceled {
+		t.Errorf("mapError() = %v, want the cancellation untouched", err)
+	}
+
+	for _, err := range []error{context.DeadlineExceeded, timeoutError{}} {
+		if mapped := mapError(err); !errors.Is(mapped, gf.ErrTimeout) || !errors.Is(mapped, err) {
+			t.Errorf("mapError(%v) = %v, want ErrTimeout wrapping it", err, mapped)
+		}
+	}
+
+	refused := errors.New("connection refused")
+	if mapped := mapError(refused); !errors.Is(mapped, gf.ErrProviderUnavailable) || !errors.Is(mapped, refused) {
+		t.Errorf("mapError() = %v, want ErrProviderUnavailable wrapping it", mapped)
+	}
+}
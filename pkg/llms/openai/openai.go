@@ -3,7 +3,9 @@ package openai
 import (
     "context"
     "fmt"
+    "strings"
     "github.com/openai/openai-go"
+    "github.com/openai/openai-go/option"
     gf "goflow/pkg/components"
 )
 
@@ -13,6 +15,26 @@ var supportedModels = map[string]bool{
     "gpt-4-1106-preview": true,
     "gpt-4-vision-preview": true,
     "gpt-3.5-turbo":     true,
+    "o1":                true,
+    "o1-mini":           true,
+    "o1-preview":        true,
+    "o3-mini":           true,
+}
+
+// reasoningModels take max_completion_tokens, reject sampling parameters
+// and report hidden reasoning tokens in their usage.
+var reasoningModels = map[string]bool{
+    "o1":         true,
+    "o1-mini":    true,
+    "o1-preview": true,
+    "o3-mini":    true,
+}
+
+// legacyReasoningModels accept neither system nor developer messages, nor
+// reasoning_effort.
+var legacyReasoningModels = map[string]bool{
+    "o1-mini":    true,
+    "o1-preview": true,
 }
 
 type OpenAIClient struct {
@@ -22,12 +44,15 @@ type OpenAIClient struct {
 }
 
 func NewOpenAIClient(config gf.ClientConfig) (*OpenAIClient, error) {
-    if err := validateModel(config.Model); err != nil {
-        return nil, err
+    // OpenAI-compatible servers, such as llama.cpp, name their own models
+    if config.BaseURL == "" {
+        if err := validateModel(config.Model); err != nil {
+            return nil, err
+        }
     }
 
-    client := openai.NewClient()
-    
+    client := openai.NewClient(requestOptions(config)...)
+
     return &OpenAIClient{
         client: client,
         config: config,
@@ -38,28 +63,234 @@ func NewOpenAIClient(config gf.ClientConfig) (*OpenAIClient, error) {
             Capabilities: map[string]bool{
                 "functions": isModelFunctionCapable(config.Model),
                 "vision":    config.Model == "gpt-4-vision-preview",
+                "reasoning": reasoningModels[config.Model],
+                "logprobs":  !reasoningModels[config.Model],
             },
         },
     }, nil
 }
 
+// requestOptions maps the client config onto SDK options. Unset fields keep
+// the SDK defaults, including reading OPENAI_API_KEY from the environment.
+func requestOptions(config gf.ClientConfig) []option.RequestOption {
+    var opts []option.RequestOption
+    if config.APIKey != "" {
+        opts = append(opts, option.WithAPIKey(config.APIKey))
+    }
+    if config.BaseURL != "" {
+        opts = append(opts, option.WithBaseURL(config.BaseURL))
+    }
+    if config.Timeout > 0 {
+        opts = append(opts, option.WithRequestTimeout(config.Timeout))
+    }
+    if config.MaxRetries > 0 {
+        opts = append(opts, option.WithMaxRetries(config.MaxRetries))
+    }
+    return opts
+}
+
 func (c *OpenAIClient) Generate(ctx context.Context, prompt gf.Prompt) (string, error) {
-    messages := []openai.ChatCompletionMessageParamUnion{
-        openai.SystemMessage(prompt.SystemMessage),
-        openai.UserMessage(prompt.UserMessage),
+    completion, err := c.Complete(ctx, prompt)
+    if err != nil {
+        return "", err
+    }
+    return completion.Choices[0].Content, nil
+}
+
+func (c *OpenAIClient) Complete(ctx context.Context, prompt gf.Prompt) (*gf.Completion, error) {
+    opts, err := c.grammarOptions(prompt)
+    if err != nil {
+        return nil, err
+    }
+    completion, err := c.client.Chat.Completions.New(ctx, c.newParams(prompt), opts...)
+    if err != nil {
+        return nil, mapError(err)
     }
+    return toCompletion(completion)
+}
 
-    completion, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-        Messages: openai.F(messages),
+// newParams builds the request for a prompt, including sampling parameters
+// and native tools when the prompt asks for them.
+func (c *OpenAIClient) newParams(prompt gf.Prompt) openai.ChatCompletionNewParams {
+    params := openai.ChatCompletionNewParams{
+        Messages: openai.F(c.buildMessages(prompt)),
         Model:    openai.F(c.modelInfo.Model),
-        Temperature: openai.Float(c.config.Temperature),
-        MaxTokens: openai.Int(int64(c.config.MaxTokens)),
-    })
+    }
+    gen := c.config.GenerationParams().Merge(prompt.Params)
+    if reasoningModels[c.modelInfo.Model] {
+        applyReasoningParams(&params, c.modelInfo.Model, gen)
+    } else {
+        applyGenerationParams(&params, gen)
+    }
+    applyTools(&params, prompt)
+    return params
+}
+
+// grammarOptions adds the prompt's GBNF grammar to the request body when
+// the client is configured with SendGrammar.
+func (c *OpenAIClient) grammarOptions(prompt gf.Prompt) ([]option.RequestOption, error) {
+    if !c.config.SendGrammar {
+        return nil, nil
+    }
+    grammar, err := prompt.OutputFormat.GBNF()
     if err != nil {
-        return "", fmt.Errorf("openai generation failed: %w", err)
+        return nil, fmt.Errorf("building output grammar: %w", err)
+    }
+    if grammar == "" {
+        return nil, nil
+    }
+    return []option.RequestOption{option.WithJSONSet("grammar", grammar)}, nil
+}
+
+// toCompletion converts an API response, rejecting empty or fully filtered
+// ones.
+func toCompletion(completion *openai.ChatCompletion) (*gf.Completion, error) {
+    if len(completion.Choices) == 0 {
+        return nil, &gf.LLMError{Kind: gf.ErrEmptyResponse, Provider: "openai", Message: "no choices returned"}
     }
 
-    return completion.Choices[0].Message.Content, nil
+    result := &gf.Completion{
+        Model: completion.Model,
+        Usage: gf.Usage{
+            PromptTokens:     completion.Usage.PromptTokens,
+            CompletionTokens: completion.Usage.CompletionTokens,
+            TotalTokens:      completion.Usage.TotalTokens,
+            ReasoningTokens:  completion.Usage.CompletionTokensDetails.ReasoningTokens,
+        },
+    }
+    for _, choice := range completion.Choices {
+        result.Choices = append(result.Choices, gf.Choice{
+            Content:      choice.Message.Content,
+            FinishReason: string(choice.FinishReason),
+            ToolCalls:    fromToolCalls(choice.Message.ToolCalls),
+            Logprobs:     fromLogprobs(choice.Logprobs.Content),
+        })
+    }
+    if allFiltered(result.Choices) {
+        return nil, &gf.LLMError{Kind: gf.ErrContentFiltered, Provider: "openai", Message: "all choices were filtered"}
+    }
+    return result, nil
+}
+
+// fromLogprobs converts token log probabilities; nil when none were asked for.
+func fromLogprobs(tokens []openai.ChatCompletionTokenLogprob) []gf.TokenLogprob {
+    if len(tokens) == 0 {
+        return nil
+    }
+    result := make([]gf.TokenLogprob, len(tokens))
+    for i, token := range tokens {
+        result[i] = gf.TokenLogprob{Token: token.Token, Logprob: token.Logprob}
+        for _, top := range token.TopLogprobs {
+            result[i].TopLogprobs = append(result[i].TopLogprobs, gf.TopLogprob{Token: top.Token, Logprob: top.Logprob})
+        }
+    }
+    return result
+}
+
+func allFiltered(choices []gf.Choice) bool {
+    for _, choice := range choices {
+        if choice.FinishReason != string(openai.ChatCompletionChoicesFinishReasonContentFilter) {
+            return false
+        }
+    }
+    return true
+}
+
+// buildMessages maps the prompt's conversation onto chat messages.
+// Reasoning models take system prompts as developer messages, and the older
+// ones that accept neither get them folded into the following user message.
+func (c *OpenAIClient) buildMessages(prompt gf.Prompt) []openai.ChatCompletionMessageParamUnion {
+    model := c.modelInfo.Model
+    var messages []openai.ChatCompletionMessageParamUnion
+    pendingSystem := ""
+
+    for _, msg := range prompt.Conversation() {
+        switch msg.Role {
+        case gf.RoleSystem:
+            switch {
+            case msg.Content == "":
+            case legacyReasoningModels[model]:
+                pendingSystem += msg.Content + "\n\n"
+            case reasoningModels[model]:
+                messages = append(messages, developerMessage(msg.Content))
+            default:
+                messages = append(messages, openai.SystemMessage(msg.Content))
+            }
+        case gf.RoleAssistant:
+            messages = append(messages, assistantMessage(msg))
+        case gf.RoleTool:
+            messages = append(messages, openai.ToolMessage(msg.ToolCallID, msg.Content))
+        default:
+            messages = append(messages, openai.UserMessage(pendingSystem+msg.Content))
+            pendingSystem = ""
+        }
+    }
+    return messages
+}
+
+func developerMessage(content string) openai.ChatCompletionMessageParamUnion {
+    return openai.ChatCompletionDeveloperMessageParam{
+        Role: openai.F(openai.ChatCompletionDeveloperMessageParamRoleDeveloper),
+        Content: openai.F([]openai.ChatCompletionContentPartTextParam{
+            openai.TextPart(content),
+        }),
+    }
+}
+
+// applyReasoningParams copies the parameters reasoning models accept. The
+// sampling knobs (temperature, top_p, penalties) are rejected by the API and
+// are dropped, and the token limit is sent as max_completion_tokens. The
+// effort is dropped for the legacy models, which reject it too.
+func applyReasoningParams(params *openai.ChatCompletionNewParams, model string, gen gf.GenerationParams) {
+    if gen.Seed != nil {
+        params.Seed = openai.Int(*gen.Seed)
+    }
+    if len(gen.Stop) > 0 {
+        params.Stop = openai.F[openai.ChatCompletionNewParamsStopUnion](openai.ChatCompletionNewParamsStopArray(gen.Stop))
+    }
+    if gen.MaxTokens != nil {
+        params.MaxCompletionTokens = openai.Int(*gen.MaxTokens)
+    }
+    if gen.N != nil {
+        params.N = openai.Int(*gen.N)
+    }
+    if gen.ReasoningEffort != "" && !legacyReasoningModels[model] {
+        params.ReasoningEffort = openai.F(openai.ChatCompletionReasoningEffort(gen.ReasoningEffort))
+    }
+}
+
+// applyGenerationParams copies every set sampling parameter onto the request.
+func applyGenerationParams(params *openai.ChatCompletionNewParams, gen gf.GenerationParams) {
+    if gen.Temperature != nil {
+        params.Temperature = openai.Float(*gen.Temperature)
+    }
+    if gen.TopP != nil {
+        params.TopP = openai.Float(*gen.TopP)
+    }
+    if gen.Seed != nil {
+        params.Seed = openai.Int(*gen.Seed)
+    }
+    if len(gen.Stop) > 0 {
+        params.Stop = openai.F[openai.ChatCompletionNewParamsStopUnion](openai.ChatCompletionNewParamsStopArray(gen.Stop))
+    }
+    if gen.PresencePenalty != nil {
+        params.PresencePenalty = openai.Float(*gen.PresencePenalty)
+    }
+    if gen.FrequencyPenalty != nil {
+        params.FrequencyPenalty = openai.Float(*gen.FrequencyPenalty)
+    }
+    if gen.MaxTokens != nil {
+        params.MaxTokens = openai.Int(*gen.MaxTokens)
+    }
+    if gen.N != nil {
+        params.N = openai.Int(*gen.N)
+    }
+    if gen.TopLogprobs != nil {
+        params.Logprobs = openai.Bool(true)
+        if *gen.TopLogprobs > 0 {
+            params.TopLogprobs = openai.Int(*gen.TopLogprobs)
+        }
+    }
 }
 
 // Helper functions remain the same
@@ -83,6 +314,10 @@ func getModelMaxTokens(model string) int64 {
         return 128000
     case "gpt-3.5-turbo":
         return 4096
+    case "o1", "o3-mini":
+        return 200000
+    case "o1-mini", "o1-preview":
+        return 128000
     default:
         return 4096
     }
@@ -96,9 +331,11 @@ func (c *OpenAIClient) GetModelInfo() gf.ModelInfo {
     return c.modelInfo
 }
 
+// ValidateResponse rejects responses with no visible text. Content checks
+// belong in the workflow's OutputParser and Guardrails.
 func (c *OpenAIClient) ValidateResponse(response string) error {
-    if response == "" {
-        return fmt.Errorf("empty response from OpenAI")
+    if strings.TrimSpace(response) == "" {
+        return &gf.LLMError{Kind: gf.ErrEmptyResponse, Provider: "openai"}
     }
     return nil
 }
\ No newline at end of file
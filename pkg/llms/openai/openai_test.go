@@ -0,0 +1,138 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"goflow/pkg/components"
+	"goflow/pkg/llms/llmtest"
+)
+
+func TestConformance(t *testing.T) {
+	llmtest.Run(t, llmtest.OpenAIVendor{}, func(baseURL string) (components.LLMClient, error) {
+		return NewOpenAIClient(components.ClientConfig{
+			Model:   "gpt-4o",
+			APIKey:  "test",
+			BaseURL: baseURL,
+		})
+	})
+}
+
+// capturedRequest starts a server that answers every chat completion with
+// a fixed response and returns the body of the last request it received.
+func capturedRequest(t *testing.T) (string, func() map[string]interface{}) {
+	t.Helper()
+	var mu sync.Mutex
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"id": "chatcmpl-1", "object": "chat.completion", "created": 1, "model": "o3-mini-2025-01-31",
+			"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "done"}}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 50, "total_tokens": 60, "completion_tokens_details": {"reasoning_tokens": 40}}
+		}`)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func() map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return body
+	}
+}
+
+func TestReasoningModelRequest(t *testing.T) {
+	tests := []struct {
+		model     string
+		roles     []string
+		effort    bool
+		firstUser string
+	}{
+		{model: "o3-mini", roles: []string{"developer", "user"}, effort: true, firstUser: "Go"},
+		{model: "o1", roles: []string{"developer", "user"}, effort: true, firstUser: "Go"},
+		{model: "o1-mini", roles: []string{"user"}, firstUser: "Be brief.\n\nGo"},
+		{model: "o1-preview", roles: []string{"user"}, firstUser: "Be brief.\n\nGo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			url, lastBody := capturedRequest(t)
+			client, err := NewOpenAIClient(components.ClientConfig{
+				Model:       tt.model,
+				APIKey:      "test",
+				BaseURL:     url,
+				Temperature: 0.7,
+				MaxTokens:   8000,
+				Params:      components.GenerationParams{ReasoningEffort: "high"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			completion, err := client.Complete(context.Background(), components.Prompt{SystemMessage: "Be brief.", UserMessage: "Go"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if completion.Usage.ReasoningTokens != 40 || completion.Usage.CompletionTokens != 50 {
+				t.Errorf("usage = %+v, want 40 reasoning tokens", completion.Usage)
+			}
+
+			body := lastBody()
+			messages := body["messages"].([]interface{})
+			var roles []string
+			for _, m := range messages {
+				roles = append(roles, m.(map[string]interface{})["role"].(string))
+			}
+			if !reflect.DeepEqual(roles, tt.roles) {
+				t.Errorf("roles = %v, want %v", roles, tt.roles)
+			}
+			last := messages[len(messages)-1].(map[string]interface{})
+			if content := fmt.Sprint(last["content"]); !strings.Contains(content, tt.firstUser) {
+				t.Errorf("user message = %v, want %q", last["content"], tt.firstUser)
+			}
+			if body["max_completion_tokens"] != 8000.0 {
+				t.Errorf("max_completion_tokens = %v, want 8000", body["max_completion_tokens"])
+			}
+			for _, key := range []string{"max_tokens", "temperature", "top_p"} {
+				if _, ok := body[key]; ok {
+					t.Errorf("request sets %s for a reasoning model", key)
+				}
+			}
+			if effort, ok := body["reasoning_effort"]; ok != tt.effort || (ok && effort != "high") {
+				t.Errorf("reasoning_effort = %v (sent %v), want sent %v", effort, ok, tt.effort)
+			}
+		})
+	}
+}
+
+func TestChatModelRequest(t *testing.T) {
+	url, lastBody := capturedRequest(t)
+	client, err := NewOpenAIClient(components.ClientConfig{Model: "gpt-4o", APIKey: "test", BaseURL: url, Temperature: 0.2, MaxTokens: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Complete(context.Background(), components.Prompt{SystemMessage: "Be brief.", UserMessage: "Go"}); err != nil {
+		t.Fatal(err)
+	}
+	body := lastBody()
+	if body["max_tokens"] != 500.0 || body["temperature"] != 0.2 {
+		t.Errorf("max_tokens = %v, temperature = %v", body["max_tokens"], body["temperature"])
+	}
+	if _, ok := body["max_completion_tokens"]; ok {
+		t.Error("request sets max_completion_tokens for a chat model")
+	}
+	if role := body["messages"].([]interface{})[0].(map[string]interface{})["role"]; role != "system" {
+		t.Errorf("first message role = %v, want system", role)
+	}
+}
@@ -0,0 +1,48 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/openai/openai-go"
+	gf "goflow/pkg/components"
+)
+
+// Stream implements gf.StreamingClient. Content deltas of the first choice
+// are passed to onDelta as they arrive; the chunks are accumulated into the
+// same Completion that Complete would have returned.
+func (c *OpenAIClient) Stream(ctx context.Context, prompt gf.Prompt, onDelta func(delta string) error) (*gf.Completion, error) {
+	params := c.newParams(prompt)
+	params.StreamOptions = openai.F(openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.F(true),
+	})
+
+	opts, err := c.grammarOptions(prompt)
+	if err != nil {
+		return nil, err
+	}
+	stream := c.client.Chat.Completions.NewStreaming(ctx, params, opts...)
+	defer stream.Close()
+
+	acc := openai.ChatCompletionAccumulator{}
+	var reasoningTokens int64
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		reasoningTokens += chunk.Usage.CompletionTokensDetails.ReasoningTokens
+
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 || choice.Delta.Content == "" {
+				continue
+			}
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	acc.Usage.CompletionTokensDetails.ReasoningTokens = reasoningTokens
+	return toCompletion(&acc.ChatCompletion)
+}
@@ -0,0 +1,89 @@
+package openai
+
+import (
+	"sort"
+
+	"github.com/openai/openai-go"
+	gf "goflow/pkg/components"
+)
+
+// applyTools sends the prompt's tools as function definitions when native
+// tool calling is enabled with Prompt.ToolChoice.
+func applyTools(params *openai.ChatCompletionNewParams, prompt gf.Prompt) {
+	if prompt.ToolChoice == "" || prompt.Tools == nil || len(prompt.Tools.Tools) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(prompt.Tools.Tools))
+	for name := range prompt.Tools.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tools []openai.ChatCompletionToolParam
+	for _, name := range names {
+		tool := prompt.Tools.Tools[name]
+		schema := tool.Schema
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		tools = append(tools, openai.ChatCompletionToolParam{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.F(name),
+				Description: openai.F(tool.Description),
+				Parameters:  openai.F(openai.FunctionParameters(schema)),
+			}),
+		})
+	}
+	params.Tools = openai.F(tools)
+
+	switch prompt.ToolChoice {
+	case gf.ToolChoiceAuto, gf.ToolChoiceRequired, gf.ToolChoiceNone:
+		params.ToolChoice = openai.F[openai.ChatCompletionToolChoiceOptionUnionParam](openai.ChatCompletionToolChoiceOptionAuto(prompt.ToolChoice))
+	default:
+		params.ToolChoice = openai.F[openai.ChatCompletionToolChoiceOptionUnionParam](openai.ChatCompletionNamedToolChoiceParam{
+			Type: openai.F(openai.ChatCompletionNamedToolChoiceTypeFunction),
+			Function: openai.F(openai.ChatCompletionNamedToolChoiceFunctionParam{
+				Name: openai.F(prompt.ToolChoice),
+			}),
+		})
+	}
+}
+
+// assistantMessage maps an assistant turn, including any tool calls it made.
+func assistantMessage(msg gf.Message) openai.ChatCompletionAssistantMessageParam {
+	param := openai.AssistantMessage(msg.Content)
+	if msg.Content == "" {
+		param.Content = openai.Null[[]openai.ChatCompletionAssistantMessageParamContentUnion]()
+	}
+	if len(msg.ToolCalls) == 0 {
+		return param
+	}
+
+	var calls []openai.ChatCompletionMessageToolCallParam
+	for _, call := range msg.ToolCalls {
+		calls = append(calls, openai.ChatCompletionMessageToolCallParam{
+			ID:   openai.F(call.ID),
+			Type: openai.F(openai.ChatCompletionMessageToolCallTypeFunction),
+			Function: openai.F(openai.ChatCompletionMessageToolCallFunctionParam{
+				Name:      openai.F(call.Name),
+				Arguments: openai.F(call.Arguments),
+			}),
+		})
+	}
+	param.ToolCalls = openai.F(calls)
+	return param
+}
+
+func fromToolCalls(calls []openai.ChatCompletionMessageToolCall) []gf.ToolCall {
+	var result []gf.ToolCall
+	for _, call := range calls {
+		result = append(result, gf.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return result
+}
@@ -0,0 +1,175 @@
+// Package prompts loads versioned prompt templates from Markdown or YAML
+// files so they can be edited without touching code:
+//
+//	//go:embed prompts
+//	var promptFiles embed.FS
+//
+//	registry := prompts.NewRegistry()
+//	if err := registry.LoadFS(promptFiles, "prompts"); err != nil {
+//		log.Fatal(err)
+//	}
+//	tmpl, err := registry.Get("domain-analysis", "1.0.0")
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when no prompt matches the requested name and version.
+var ErrNotFound = errors.New("prompt not found")
+
+// Latest selects the highest version of a prompt in Registry.Get.
+const Latest = "latest"
+
+// Registry holds prompt templates by name and version.
+type Registry struct {
+	mu        sync.RWMutex
+	templates map[string]map[string]*Template
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{templates: make(map[string]map[string]*Template)}
+}
+
+// Register adds a template. Registering the same name and version twice is
+// an error so two files cannot silently shadow each other.
+func (r *Registry) Register(tmpl *Template) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions, ok := r.templates[tmpl.Name]
+	if !ok {
+		versions = make(map[string]*Template)
+		r.templates[tmpl.Name] = versions
+	}
+	if existing, ok := versions[tmpl.Version]; ok {
+		return fmt.Errorf("prompt %s@%s from %s already registered from %s", tmpl.Name, tmpl.Version, tmpl.Source, existing.Source)
+	}
+	versions[tmpl.Version] = tmpl
+	return nil
+}
+
+// LoadFS registers every .md, .markdown, .yaml and .yml file under root in
+// fsys. It works with embed.FS as well as os.DirFS.
+func (r *Registry) LoadFS(fsys fs.FS, root string) error {
+	return fs.WalkDir(fsys, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(path.Ext(name)) {
+		case ".md", ".markdown", ".yaml", ".yml":
+		default:
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		tmpl, err := Parse(name, data)
+		if err != nil {
+			return err
+		}
+		return r.Register(tmpl)
+	})
+}
+
+// LoadDir registers every prompt file under dir on disk.
+func (r *Registry) LoadDir(dir string) error {
+	return r.LoadFS(os.DirFS(dir), ".")
+}
+
+// Get returns the named prompt at version, or the highest version when
+// version is empty or Latest.
+func (r *Registry) Get(name, version string) (*Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if version == "" || version == Latest {
+		sorted := sortedVersions(versions)
+		return versions[sorted[len(sorted)-1]], nil
+	}
+	tmpl, ok := versions[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s@%s", ErrNotFound, name, version)
+	}
+	return tmpl, nil
+}
+
+// Versions lists the registered versions of a prompt, lowest first.
+func (r *Registry) Versions(name string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sortedVersions(r.templates[name])
+}
+
+// Names lists every registered prompt name.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedVersions(versions map[string]*Template) []string {
+	sorted := make([]string, 0, len(versions))
+	for version := range versions {
+		sorted = append(sorted, version)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return compareVersions(sorted[i], sorted[j]) < 0
+	})
+	return sorted
+}
+
+// compareVersions orders dotted versions numerically part by part, so
+// 1.10 sorts after 1.9. A leading "v" is ignored and non-numeric parts
+// compare as strings.
+func compareVersions(a, b string) int {
+	partsA := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	partsB := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var pa, pb string
+		if i < len(partsA) {
+			pa = partsA[i]
+		}
+		if i < len(partsB) {
+			pb = partsB[i]
+		}
+		na, errA := strconv.Atoi(pa)
+		nb, errB := strconv.Atoi(pb)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case pa != pb:
+			return strings.Compare(pa, pb)
+		}
+	}
+	return 0
+}
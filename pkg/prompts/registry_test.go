@@ -0,0 +1,83 @@
+package prompts
+
+import (
+	"embed"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+//go:embed testdata/prompts
+var testPrompts embed.FS
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.9.0", "1.10.0", -1},
+		{"1.10", "1.9", 1},
+		{"v2", "2", 0},
+		{"1.0", "1.0.1", -1},
+		{"2.0.0", "10.0.0", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+		{"1.a", "1.1", 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLoadFSEmbedded(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.LoadFS(testPrompts, "testdata/prompts"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := registry.Names(), []string{"classify", "summarize"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+	if got, want := registry.Versions("summarize"), []string{"1.9.0", "1.10.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Versions() = %v, want %v", got, want)
+	}
+
+	latest, err := registry.Get("summarize", Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Version != "1.10.0" || latest.Source != "testdata/prompts/nested/summarize-v2.yaml" {
+		t.Errorf("latest = %s from %s, want 1.10.0 from the nested YAML file", latest.Version, latest.Source)
+	}
+	pinned, err := registry.Get("summarize", "1.9.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned.System != "Summarize in {{.length}} sentences." || pinned.User != "{{.text}}" {
+		t.Errorf("sections = %q, %q", pinned.System, pinned.User)
+	}
+
+	for _, version := range []string{"2.0.0", ""} {
+		name := "summarize"
+		if version == "" {
+			name = "translate"
+		}
+		if _, err := registry.Get(name, version); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q, %q) error = %v, want ErrNotFound", name, version, err)
+		}
+	}
+}
+
+func TestLoadFSRejectsDuplicates(t *testing.T) {
+	prompt := &fstest.MapFile{Data: []byte("name: greet\nversion: 1.0.0\nsystem: Hi\n")}
+	fsys := fstest.MapFS{"a/greet.yaml": prompt, "b/greet.yml": prompt}
+
+	err := NewRegistry().LoadFS(fsys, ".")
+	if err == nil || !strings.Contains(err.Error(), "greet@1.0.0") || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("LoadFS() error = %v, want a duplicate registration error", err)
+	}
+}
@@ -0,0 +1,232 @@
+package prompts
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"goflow/pkg/components"
+	"gopkg.in/yaml.v3"
+)
+
+// Template is a prompt loaded from a Markdown or YAML file.
+type Template struct {
+	Name        string     `yaml:"name"`
+	Version     string     `yaml:"version"`
+	Description string     `yaml:"description"`
+	Model       ModelHints `yaml:"model"`
+	Variables   []Variable `yaml:"variables"`
+	Output      Output     `yaml:"output"`
+	Tools       []string   `yaml:"tools"`
+	System      string     `yaml:"system"`
+	User        string     `yaml:"user"`
+
+	// Source is the file the template was loaded from.
+	Source string `yaml:"-"`
+}
+
+// ModelHints are the model and sampling settings the prompt was tuned for.
+type ModelHints struct {
+	Name            string   `yaml:"name"`
+	Temperature     *float64 `yaml:"temperature"`
+	TopP            *float64 `yaml:"top_p"`
+	Seed            *int64   `yaml:"seed"`
+	MaxTokens       *int64   `yaml:"max_tokens"`
+	ReasoningEffort string   `yaml:"reasoning_effort"`
+}
+
+// Variable documents a template variable.
+type Variable struct {
+	Name        string      `yaml:"name"`
+	Description string      `yaml:"description"`
+	Required    bool        `yaml:"required"`
+	Default     interface{} `yaml:"default"`
+}
+
+// Output describes the expected response format. Type is one of json,
+// json_list, jsonl, yaml, xml, regex or classification; for json_list and
+// jsonl the schema describes one record; regex output also needs a Pattern
+// with a named group per field and a Layout to show the model, and
+// classification output needs its Labels.
+type Output struct {
+	Type        string                   `yaml:"type"`
+	Description string                   `yaml:"description"`
+	Schema      []components.SchemaField `yaml:"schema"`
+	Pattern     string                   `yaml:"pattern"`
+	Layout      string                   `yaml:"layout"`
+	Labels      []string                 `yaml:"labels"`
+	Grammar     string                   `yaml:"grammar"`
+}
+
+// sectionHeading matches the "# System" and "# User" headings that split a
+// Markdown body into messages.
+var sectionHeading = regexp.MustCompile(`(?im)^#{1,6}[ \t]+(system|user)[ \t]*$`)
+
+// Parse reads a template from a Markdown file with YAML front matter or from
+// a plain YAML file, chosen by the name's extension. In Markdown files the
+// body is split into messages by "# System" and "# User" headings; a body
+// without headings is the system message.
+func Parse(name string, data []byte) (*Template, error) {
+	tmpl := &Template{Source: name}
+
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, tmpl); err != nil {
+			return nil, fmt.Errorf("%s: parsing yaml: %w", name, err)
+		}
+	case ".md", ".markdown":
+		frontMatter, body, err := splitFrontMatter(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := yaml.Unmarshal(frontMatter, tmpl); err != nil {
+			return nil, fmt.Errorf("%s: parsing front matter: %w", name, err)
+		}
+		tmpl.System, tmpl.User = splitSections(body)
+	default:
+		return nil, fmt.Errorf("%s: unsupported prompt file type", name)
+	}
+
+	if tmpl.Name == "" {
+		return nil, fmt.Errorf("%s: prompt has no name", name)
+	}
+	if tmpl.Version == "" {
+		return nil, fmt.Errorf("%s: prompt %q has no version", name, tmpl.Name)
+	}
+	if tmpl.Output.Type == components.OutputRegex {
+		if _, err := components.NewRegexParser(tmpl.Output.Pattern, tmpl.Output.Schema); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if tmpl.Output.Type == components.OutputClassification && len(tmpl.Output.Labels) == 0 {
+		return nil, fmt.Errorf("%s: classification output has no labels", name)
+	}
+	return tmpl, nil
+}
+
+func splitFrontMatter(data []byte) ([]byte, string, error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(data, []byte("---\n")) {
+		return nil, "", fmt.Errorf("missing front matter")
+	}
+	rest := data[len("---\n"):]
+	end := bytes.Index(rest, []byte("\n---\n"))
+	if end < 0 {
+		if !bytes.HasSuffix(rest, []byte("\n---")) {
+			return nil, "", fmt.Errorf("unterminated front matter")
+		}
+		return rest[:len(rest)-len("\n---")], "", nil
+	}
+	return rest[:end], string(rest[end+len("\n---\n"):]), nil
+}
+
+func splitSections(body string) (string, string) {
+	matches := sectionHeading.FindAllStringSubmatchIndex(body, -1)
+	if len(matches) == 0 {
+		return strings.TrimSpace(body), ""
+	}
+
+	var system, user string
+	for i, m := range matches {
+		end := len(body)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		text := strings.TrimSpace(body[m[1]:end])
+		if strings.EqualFold(body[m[2]:m[3]], "system") {
+			system = text
+		} else {
+			user = text
+		}
+	}
+	return system, user
+}
+
+// Prompt builds a components.Prompt from the template. Variable defaults
+// fill in missing values, required variables must be supplied, the model
+// hints become the prompt's generation parameters and only the tools the
+// template names are taken from tools.
+func (t *Template) Prompt(variables map[string]interface{}, tools *components.ToolList) (components.Prompt, error) {
+	values := make(map[string]interface{}, len(variables))
+	for key, value := range variables {
+		values[key] = value
+	}
+	for _, v := range t.Variables {
+		if _, ok := values[v.Name]; ok {
+			continue
+		}
+		if v.Default != nil {
+			values[v.Name] = v.Default
+		} else if v.Required {
+			return components.Prompt{}, fmt.Errorf("prompt %s@%s: missing required variable %q", t.Name, t.Version, v.Name)
+		}
+	}
+
+	prompt := components.Prompt{
+		SystemMessage: t.System,
+		UserMessage:   t.User,
+		Variables:     values,
+		Params: components.GenerationParams{
+			Temperature:     t.Model.Temperature,
+			TopP:            t.Model.TopP,
+			Seed:            t.Model.Seed,
+			MaxTokens:       t.Model.MaxTokens,
+			ReasoningEffort: t.Model.ReasoningEffort,
+		},
+	}
+
+	if t.Output.Type != "" {
+		prompt.OutputFormat = components.OutputFormat{
+			Type:        t.Output.Type,
+			Description: t.Output.Description,
+			Fields:      t.Output.Schema,
+			Layout:      t.Output.Layout,
+			Labels:      t.Output.Labels,
+			Grammar:     t.Output.Grammar,
+		}
+		if len(t.Output.Schema) > 0 {
+			schema := &components.JSONSchemaBuilder{Fields: t.Output.Schema}
+			prompt.OutputFormat.Schema = schema.Build()
+		}
+	}
+
+	if len(t.Tools) > 0 {
+		if tools == nil {
+			return components.Prompt{}, fmt.Errorf("prompt %s@%s: requires tools %v but none were given", t.Name, t.Version, t.Tools)
+		}
+		selected := make(map[string]components.Tool, len(t.Tools))
+		for _, name := range t.Tools {
+			tool, ok := tools.Tools[name]
+			if !ok {
+				return components.Prompt{}, fmt.Errorf("prompt %s@%s: tool %q not available", t.Name, t.Version, name)
+			}
+			selected[name] = tool
+		}
+		prompt.Tools = &components.ToolList{Tools: selected}
+	}
+
+	return prompt, nil
+}
+
+// Parser returns a parser for the template's output type and schema.
+func (t *Template) Parser() components.OutputParser {
+	switch t.Output.Type {
+	case components.OutputYAML:
+		return components.NewYAMLParser(t.Output.Schema)
+	case components.OutputXML:
+		return components.NewXMLTagParser(t.Output.Schema)
+	case components.OutputRegex:
+		// Parse has already checked the pattern
+		parser, _ := components.NewRegexParser(t.Output.Pattern, t.Output.Schema)
+		return parser
+	case components.OutputClassification:
+		return components.NewClassificationParser(t.Output.Labels)
+	case components.OutputJSONList:
+		return components.NewJSONListParser(t.Output.Schema)
+	case components.OutputJSONLines:
+		return components.NewJSONLinesParser(t.Output.Schema)
+	}
+	return components.NewJSONParser(t.Output.Schema)
+}
@@ -0,0 +1,137 @@
+package prompts
+
+import (
+	"strings"
+	"testing"
+
+	"goflow/pkg/components"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		frontMatter string
+		body        string
+		err         string
+	}{
+		{name: "body", data: "---\nname: a\n---\nHello\n", frontMatter: "name: a", body: "Hello\n"},
+		{name: "crlf", data: "---\r\nname: a\r\n---\r\nHello", frontMatter: "name: a", body: "Hello"},
+		{name: "no body", data: "---\nname: a\n---", frontMatter: "name: a"},
+		{name: "missing", data: "name: a\n", err: "missing front matter"},
+		{name: "unterminated", data: "---\nname: a\nHello\n", err: "unterminated front matter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frontMatter, body, err := splitFrontMatter([]byte(tt.data))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(frontMatter) != tt.frontMatter || body != tt.body {
+				t.Errorf("got %q, %q; want %q, %q", frontMatter, body, tt.frontMatter, tt.body)
+			}
+		})
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		system, user string
+	}{
+		{name: "no headings", body: "\nBe helpful.\n", system: "Be helpful."},
+		{name: "both", body: "# System\nBe helpful.\n\n# User\nHi {{.name}}\n", system: "Be helpful.", user: "Hi {{.name}}"},
+		{name: "user first", body: "## user\nHi\n### SYSTEM\nBe brief.", system: "Be brief.", user: "Hi"},
+		{name: "other headings stay", body: "# System\n## Rules\n1. Be brief.\n# User\nHi", system: "## Rules\n1. Be brief.", user: "Hi"},
+		{name: "heading needs its own line", body: "# System prompt\nBe brief.", system: "# System prompt\nBe brief."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system, user := splitSections(tt.body)
+			if system != tt.system || user != tt.user {
+				t.Errorf("splitSections() = %q, %q; want %q, %q", system, user, tt.system, tt.user)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		file, data, err string
+	}{
+		{"a.txt", "name: a", "unsupported prompt file type"},
+		{"a.yaml", "version: 1.0.0", "has no name"},
+		{"a.md", "---\nname: a\n---\nHi", "has no version"},
+		{"a.yaml", "name: a\nversion: 1\noutput:\n  type: classification", "no labels"},
+		{"a.yaml", "name: a\nversion: 1\noutput:\n  type: regex\n  pattern: 'x'\n  schema:\n    - field: score\n      required: true", "no group for required field score"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.file, []byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%s, %q) error = %v, want %q", tt.file, tt.data, err, tt.err)
+		}
+	}
+}
+
+func TestTemplatePrompt(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.LoadFS(testPrompts, "testdata/prompts"); err != nil {
+		t.Fatal(err)
+	}
+	v1, err := registry.Get("summarize", "1.9.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v1.Prompt(nil, nil); err == nil || !strings.Contains(err.Error(), `missing required variable "text"`) {
+		t.Errorf("Prompt() without text error = %v", err)
+	}
+	prompt, err := v1.Prompt(map[string]interface{}{"text": "Go is fun."}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt.Variables["length"] != 3 {
+		t.Errorf("length = %v, want the default 3", prompt.Variables["length"])
+	}
+	if prompt.Params.Temperature == nil || *prompt.Params.Temperature != 0.2 {
+		t.Errorf("temperature = %v, want 0.2 from the model hints", prompt.Params.Temperature)
+	}
+	rendered, err := prompt.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.SystemMessage != "Summarize in 3 sentences." || rendered.UserMessage != "Go is fun." {
+		t.Errorf("rendered = %q, %q", rendered.SystemMessage, rendered.UserMessage)
+	}
+
+	v2, err := registry.Get("summarize", Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v2.Prompt(map[string]interface{}{"text": "x"}, nil); err == nil {
+		t.Error("Prompt() without tools succeeded for a template that names whois")
+	}
+	tools := &components.ToolList{Tools: map[string]components.Tool{
+		"whois": {Name: "whois"},
+		"shell": {Name: "shell"},
+	}}
+	prompt, err = v2.Prompt(map[string]interface{}{"text": "x"}, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompt.Tools.Tools) != 1 || prompt.Tools.Tools["whois"].Name != "whois" {
+		t.Errorf("tools = %v, want only whois", prompt.Tools.Tools)
+	}
+	if prompt.OutputFormat.Type != components.OutputJSON || prompt.OutputFormat.Schema == nil {
+		t.Errorf("output format = %+v, want JSON with a schema", prompt.OutputFormat)
+	}
+	if _, ok := v2.Parser().(*components.JSONParser); !ok {
+		t.Errorf("Parser() = %T, want *components.JSONParser", v2.Parser())
+	}
+}
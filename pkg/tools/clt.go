@@ -1,37 +1,32 @@
 package tools
 
 import (
-
+    "context"
     "fmt"
     "os/exec"
 	"goflow/pkg/components"
-	"encoding/json"
 )
 
 type WhoisInput struct {
-    Domain string`json:"domain"`
+    Domain string `json:"domain" description:"Domain name to look up, e.g. example.com" format:"hostname"`
 }
 
 func CreateWhoisTool() components.Tool {
-    return components.Tool{
-        Name:        "whois",
-        Description: "Performs a whois lookup for a domain. This tool will only provide new information when looking up a unique input.",
-        Inputs:      WhoisInput{},
-        HandlerFunc: handleWhois,
-    }
+    return components.MustTool(
+        "whois",
+        "Performs a whois lookup for a domain. This tool will only provide new information when looking up a unique input.",
+        handleWhois,
+    )
 }
 
-func handleWhois(inputs interface{}) (interface{}, error) {
-    
-	var inputValue WhoisInput
-    json.Unmarshal([]byte(inputs.(string)), &inputValue)
-
-    cmd := exec.Command("whois", inputValue.Domain)
+func handleWhois(ctx context.Context, input WhoisInput) (string, error) {
+    // Killed when the tool's timeout or the caller's context expires
+    cmd := exec.CommandContext(ctx, "whois", input.Domain)
     output, err := cmd.CombinedOutput()
 	
     if err != nil {
-        return nil, fmt.Errorf("whois command failed: %v", err)
+        return "", fmt.Errorf("whois command failed: %v", err)
     }
 
     return string(output), nil
-}
\ No newline at end of file
+}
@@ -1,80 +1,61 @@
 package tools
 
 import (
+	"context"
 	"goflow/pkg/components"
 	"fmt"
-	"os"
-	"encoding/json"
 )
 
 
 // File tool input structures
 type ReadFileInput struct {
-    Path string `json:"path"`
+    Path string `json:"path" description:"Path of the file to read, relative to the sandbox directory"`
 }
 
 type WriteFileInput struct {
-    Path string `json:"path"`
-    Data string `json:"data"`
+    Path string `json:"path" description:"Path of the file to write, relative to the sandbox directory"`
+    Data string `json:"data" description:"Content to write"`
+}
+
+type WriteFileOutput struct {
+    Status string `json:"status"`
+    Path   string `json:"path"`
 }
 
 
-func FileReadTool() components.Tool {
-	return components.Tool{
-		Name:        "readFile",
-		Description: "Reads content from a file at the specified path",
-		Inputs:      ReadFileInput{},
-		HandlerFunc: handleReadFile,
-	}
+// FileReadTool reads files inside sandbox.
+func FileReadTool(sandbox *Sandbox) components.Tool {
+	return components.MustTool("readFile", "Reads content from a file at the specified path", readFileHandler(sandbox))
 }
 
 
-func FileWriteTool() components.Tool {
-	return components.Tool{
-		Name:        "writeFile",
-		Description: "Writes content to a file at the specified path",
-		Inputs:      WriteFileInput{},
-		HandlerFunc: handleWriteFile,
-	}
+// FileWriteTool writes files inside sandbox. Leave it out of the tool list
+// when the sandbox is read-only; it would only return ErrReadOnly.
+func FileWriteTool(sandbox *Sandbox) components.Tool {
+	return components.MustTool("writeFile", "Writes content to a file at the specified path", writeFileHandler(sandbox))
 }
 
 
 
 
 
-func handleReadFile(inputs interface{}) (interface{}, error) {
-    var input ReadFileInput
-    inputJSON, err := json.Marshal(inputs)
-    if err != nil {
-        return nil, fmt.Errorf("failed to marshal input: %v", err)
-    }
-    
-    if err := json.Unmarshal(inputJSON, &input); err != nil {
-        return nil, fmt.Errorf("failed to parse input: %v", err)
-    }
+func readFileHandler(sandbox *Sandbox) func(context.Context, ReadFileInput) (string, error) {
+    return func(ctx context.Context, input ReadFileInput) (string, error) {
+        content, err := sandbox.ReadFile(input.Path)
+        if err != nil {
+            return "", fmt.Errorf("failed to read file: %w", err)
+        }
 
-    content, err := os.ReadFile(input.Path)
-    if err != nil {
-        return nil, fmt.Errorf("failed to read file: %v", err)
+        return string(content), nil
     }
-
-    return string(content), nil
 }
 
-func handleWriteFile(inputs interface{}) (interface{}, error) {
-    var input WriteFileInput
-    inputJSON, err := json.Marshal(inputs)
-    if err != nil {
-        return nil, fmt.Errorf("failed to marshal input: %v", err)
-    }
-    
-    if err := json.Unmarshal(inputJSON, &input); err != nil {
-        return nil, fmt.Errorf("failed to parse input: %v", err)
-    }
+func writeFileHandler(sandbox *Sandbox) func(context.Context, WriteFileInput) (WriteFileOutput, error) {
+    return func(ctx context.Context, input WriteFileInput) (WriteFileOutput, error) {
+        if err := sandbox.WriteFile(input.Path, []byte(input.Data)); err != nil {
+            return WriteFileOutput{}, fmt.Errorf("failed to write file: %w", err)
+        }
 
-    if err := os.WriteFile(input.Path, []byte(input.Data), 0644); err != nil {
-        return nil, fmt.Errorf("failed to write file: %v", err)
+        return WriteFileOutput{Status: "success", Path: input.Path}, nil
     }
-
-    return map[string]string{"status": "success", "path": input.Path}, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,286 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxFileSize limits reads and writes when Sandbox.MaxReadBytes or
+// MaxWriteBytes is zero.
+const DefaultMaxFileSize = 1 << 20
+
+// Errors returned by Sandbox, usable with errors.Is.
+var (
+	ErrOutsideSandbox = errors.New("path is outside the sandbox")
+	ErrPathDenied     = errors.New("path is not allowed")
+	ErrReadOnly       = errors.New("sandbox is read-only")
+	ErrFileTooLarge   = errors.New("file is too large")
+)
+
+// Sandbox confines the file tools to one directory. Paths are taken
+// relative to the root, and a path that leaves it, directly, through ".."
+// or through a symlink, is refused. The checks guard against paths chosen
+// by the model; they do not defend against another process swapping
+// directories for symlinks inside the root while a tool runs.
+type Sandbox struct {
+	root string
+
+	// Allow and Deny are glob patterns matched against slash-separated
+	// paths relative to the root. "**" matches any number of directories
+	// and a pattern without a slash is matched against the file name only,
+	// so "*.md" allows Markdown files anywhere and "**/.git/**" denies a
+	// repository's internals. An empty Allow permits every path; Deny wins
+	// over Allow.
+	Allow []string
+	Deny  []string
+	// ReadOnly refuses every write.
+	ReadOnly bool
+	// MaxReadBytes and MaxWriteBytes cap file sizes. Zero means
+	// DefaultMaxFileSize and a negative value no limit.
+	MaxReadBytes  int64
+	MaxWriteBytes int64
+	// FileMode is the permission of newly written files, 0600 if zero.
+	// Overwritten files keep their mode.
+	FileMode fs.FileMode
+}
+
+// NewSandbox creates a sandbox rooted at dir, which must exist.
+func NewSandbox(dir string) (*Sandbox, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox root: %w", err)
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, fmt.Errorf("sandbox root: %w", err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox root: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("sandbox root %s is not a directory", dir)
+	}
+	return &Sandbox{root: root}, nil
+}
+
+// NewTempSandbox creates a sandbox in a new temporary directory, named as
+// by os.MkdirTemp, for a single run. The caller removes it with
+// os.RemoveAll(sandbox.Root()).
+func NewTempSandbox(pattern string) (*Sandbox, error) {
+	dir, err := os.MkdirTemp("", pattern)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox root: %w", err)
+	}
+	return NewSandbox(dir)
+}
+
+// Root returns the absolute, symlink-free sandbox directory.
+func (s *Sandbox) Root() string {
+	return s.root
+}
+
+// ReadFile reads a file inside the sandbox.
+func (s *Sandbox) ReadFile(name string) ([]byte, error) {
+	target, err := s.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(target)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+
+	limit := sizeLimit(s.MaxReadBytes)
+	if limit >= 0 && info.Size() > limit {
+		return nil, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrFileTooLarge, name, info.Size(), limit)
+	}
+	reader := io.Reader(file)
+	if limit >= 0 {
+		// The file may have grown since Stat
+		reader = io.LimitReader(file, limit+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if limit >= 0 && int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %s is over %d bytes", ErrFileTooLarge, name, limit)
+	}
+	return data, nil
+}
+
+// WriteFile replaces a file inside the sandbox atomically: the data goes
+// to a temporary file in the same directory, which is then renamed over
+// the target, so readers never see a partial file. Missing parent
+// directories are created.
+func (s *Sandbox) WriteFile(name string, data []byte) error {
+	if s.ReadOnly {
+		return fmt.Errorf("%w: cannot write %s", ErrReadOnly, name)
+	}
+	if limit := sizeLimit(s.MaxWriteBytes); limit >= 0 && int64(len(data)) > limit {
+		return fmt.Errorf("%w: %d bytes for %s, the limit is %d", ErrFileTooLarge, len(data), name, limit)
+	}
+	target, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+
+	mode := s.FileMode
+	if mode == 0 {
+		mode = 0600
+	}
+	if info, err := os.Stat(target); err == nil {
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", name)
+		}
+		mode = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Chmod(mode); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), target)
+}
+
+// resolve maps a requested path to the target path it would touch and checks
+// that both are inside the root and permitted. Symlinks are followed as far
+// as the path exists; the rest will be created as plain entries.
+func (s *Sandbox) resolve(name string) (string, error) {
+	if s == nil || s.root == "" {
+		return "", errors.New("sandbox has no root; use NewSandbox")
+	}
+	if strings.TrimSpace(name) == "" {
+		return "", errors.New("path must not be empty")
+	}
+
+	requested := filepath.Clean(name)
+	if !filepath.IsAbs(requested) {
+		requested = filepath.Join(s.root, requested)
+	}
+	if err := s.check(name, requested); err != nil {
+		return "", err
+	}
+
+	existing, rest := requested, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+	target, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		// A dangling symlink cannot be checked
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	target = filepath.Join(target, rest)
+	if err := s.check(name, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// check reports whether an absolute path is inside the root and permitted.
+func (s *Sandbox) check(name, abs string) error {
+	rel, err := filepath.Rel(s.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%w: %s", ErrOutsideSandbox, name)
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		return fmt.Errorf("%s is the sandbox root, not a file", name)
+	}
+	for _, pattern := range s.Deny {
+		if globMatch(pattern, rel) {
+			return fmt.Errorf("%w: %s matches %q", ErrPathDenied, name, pattern)
+		}
+	}
+	if len(s.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range s.Allow {
+		if globMatch(pattern, rel) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s matches no allowed pattern", ErrPathDenied, name)
+}
+
+// globMatch matches a slash-separated relative path against pattern as
+// described on Sandbox.Allow. Malformed patterns match nothing.
+func globMatch(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchElements(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(rel, "/"))
+}
+
+func matchElements(pattern, elements []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elements); i++ {
+				if matchElements(pattern[1:], elements[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elements) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elements[0]); !ok {
+			return false
+		}
+		pattern, elements = pattern[1:], elements[1:]
+	}
+	return len(elements) == 0
+}
+
+func sizeLimit(limit int64) int64 {
+	if limit == 0 {
+		return DefaultMaxFileSize
+	}
+	if limit < 0 {
+		return -1
+	}
+	return limit
+}
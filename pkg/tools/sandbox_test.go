@@ -0,0 +1,243 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestSandbox returns a sandbox over a temporary directory holding
+// notes.md, secret.pem and keys/id.pem, with symlinks pointing inside and
+// outside the root. The directory outside the root holds outside.txt.
+func newTestSandbox(t *testing.T) (*Sandbox, string) {
+	t.Helper()
+	root, outside := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(root, "notes.md"):            "notes",
+		filepath.Join(root, "secret.pem"):          "secret",
+		filepath.Join(root, "keys", "id.pem"):      "key",
+		filepath.Join(outside, "outside.txt"):      "outside",
+		filepath.Join(root, "docs", "guide.md"):    "guide",
+		filepath.Join(root, "docs", "image.png"):   "png",
+		filepath.Join(root, ".git", "config"):      "git",
+		filepath.Join(root, "nested", "a", "b.md"): "deep",
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"out-file": filepath.Join(outside, "outside.txt"),
+		"out-dir":  outside,
+		"alias":    "secret.pem",
+		"key-dir":  "keys",
+		"notes-ln": "notes.md",
+		"dangling": "missing.txt",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+	sandbox, err := NewSandbox(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sandbox, outside
+}
+
+func TestSandboxResolve(t *testing.T) {
+	sandbox, outside := newTestSandbox(t)
+	sandbox.Deny = []string{"*.pem", "**/.git/**"}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string // relative to the root
+		wantErr error
+	}{
+		{name: "plain file", path: "notes.md", want: "notes.md"},
+		{name: "nested file", path: "nested/a/b.md", want: "nested/a/b.md"},
+		{name: "new file in new directory", path: "new/dir/file.txt", want: "new/dir/file.txt"},
+		{name: "symlink inside root", path: "notes-ln", want: "notes.md"},
+		{name: "dot dot inside root", path: "docs/../notes.md", want: "notes.md"},
+		{name: "absolute path inside root", path: filepath.Join(sandbox.Root(), "notes.md"), want: "notes.md"},
+		{name: "dot dot out of root", path: "../outside.txt", wantErr: ErrOutsideSandbox},
+		{name: "dot dot through subdirectory", path: "docs/../../outside.txt", wantErr: ErrOutsideSandbox},
+		{name: "absolute path outside root", path: filepath.Join(outside, "outside.txt"), wantErr: ErrOutsideSandbox},
+		{name: "symlink file to outside", path: "out-file", wantErr: ErrOutsideSandbox},
+		{name: "symlink directory to outside", path: "out-dir/outside.txt", wantErr: ErrOutsideSandbox},
+		{name: "new file under symlink directory to outside", path: "out-dir/new.txt", wantErr: ErrOutsideSandbox},
+		{name: "denied file", path: "secret.pem", wantErr: ErrPathDenied},
+		{name: "denied file through alias", path: "alias", wantErr: ErrPathDenied},
+		{name: "denied file through directory alias", path: "key-dir/id.pem", wantErr: ErrPathDenied},
+		{name: "denied directory", path: ".git/config", wantErr: ErrPathDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sandbox.resolve(tt.path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("resolve(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve(%q): %v", tt.path, err)
+			}
+			if want := filepath.Join(sandbox.Root(), tt.want); got != want {
+				t.Errorf("resolve(%q) = %q, want %q", tt.path, got, want)
+			}
+		})
+	}
+}
+
+func TestSandboxResolveRejects(t *testing.T) {
+	sandbox, _ := newTestSandbox(t)
+	for _, path := range []string{"", "  ", ".", "docs/..", "dangling"} {
+		if _, err := sandbox.resolve(path); err == nil {
+			t.Errorf("resolve(%q) succeeded, want an error", path)
+		}
+	}
+	if _, err := (&Sandbox{}).resolve("notes.md"); err == nil {
+		t.Error("resolve on a sandbox without a root succeeded")
+	}
+}
+
+func TestSandboxCheckPatterns(t *testing.T) {
+	sandbox, _ := newTestSandbox(t)
+	sandbox.Allow = []string{"*.md", "docs/**"}
+	sandbox.Deny = []string{"docs/*.png"}
+
+	tests := []struct {
+		path    string
+		wantErr error
+	}{
+		{path: "notes.md"},
+		{path: "nested/a/b.md"},
+		{path: "docs/guide.md"},
+		{path: "docs/sub/data.json"},
+		{path: "docs/image.png", wantErr: ErrPathDenied},
+		{path: "secret.pem", wantErr: ErrPathDenied},
+		{path: "nested/a/b.txt", wantErr: ErrPathDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := sandbox.check(tt.path, filepath.Join(sandbox.Root(), tt.path))
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("check(%q): %v", tt.path, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("check(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSandboxReadOnly(t *testing.T) {
+	sandbox, _ := newTestSandbox(t)
+	sandbox.ReadOnly = true
+
+	if err := sandbox.WriteFile("notes.md", []byte("changed")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("WriteFile error = %v, want ErrReadOnly", err)
+	}
+	data, err := sandbox.ReadFile("notes.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "notes" {
+		t.Errorf("notes.md = %q after a refused write", data)
+	}
+}
+
+func TestSandboxSizeLimits(t *testing.T) {
+	sandbox, _ := newTestSandbox(t)
+	sandbox.MaxReadBytes = 4
+	sandbox.MaxWriteBytes = 4
+
+	if _, err := sandbox.ReadFile("notes.md"); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("ReadFile of 5 bytes error = %v, want ErrFileTooLarge", err)
+	}
+	if _, err := sandbox.ReadFile("keys/id.pem"); err != nil {
+		t.Errorf("ReadFile of 3 bytes: %v", err)
+	}
+	if err := sandbox.WriteFile("out.txt", []byte("12345")); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("WriteFile of 5 bytes error = %v, want ErrFileTooLarge", err)
+	}
+	if err := sandbox.WriteFile("out.txt", []byte("1234")); err != nil {
+		t.Errorf("WriteFile of 4 bytes: %v", err)
+	}
+
+	sandbox.MaxReadBytes, sandbox.MaxWriteBytes = -1, -1
+	if _, err := sandbox.ReadFile("notes.md"); err != nil {
+		t.Errorf("ReadFile without a limit: %v", err)
+	}
+}
+
+func TestSandboxWriteFile(t *testing.T) {
+	sandbox, _ := newTestSandbox(t)
+
+	if err := sandbox.WriteFile("new/dir/file.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(sandbox.Root(), "new", "dir", "file.txt")
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("new file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := os.Chmod(target, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sandbox.WriteFile("new/dir/file.txt", []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0644 {
+		t.Errorf("overwritten file mode = %v, want 0644", info.Mode().Perm())
+	}
+	data, err := sandbox.ReadFile("new/dir/file.txt")
+	if err != nil || string(data) != "again" {
+		t.Errorf("ReadFile = %q, %v, want %q", data, err, "again")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestFileTools(t *testing.T) {
+	sandbox, _ := newTestSandbox(t)
+	sandbox.Deny = []string{"*.pem"}
+
+	write := FileWriteTool(sandbox)
+	if _, err := write.HandlerFunc(context.Background(), map[string]interface{}{"path": "out.txt", "data": "hi"}); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	read := FileReadTool(sandbox)
+	got, err := read.HandlerFunc(context.Background(), map[string]interface{}{"path": "out.txt"})
+	if err != nil || got != "hi" {
+		t.Errorf("readFile = %v, %v, want %q", got, err, "hi")
+	}
+	if _, err := read.HandlerFunc(context.Background(), map[string]interface{}{"path": "alias"}); !errors.Is(err, ErrPathDenied) {
+		t.Errorf("readFile through alias error = %v, want ErrPathDenied", err)
+	}
+	if _, err := read.HandlerFunc(context.Background(), map[string]interface{}{"path": "out-file"}); !errors.Is(err, ErrOutsideSandbox) {
+		t.Errorf("readFile through outside symlink error = %v, want ErrOutsideSandbox", err)
+	}
+}